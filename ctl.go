@@ -0,0 +1,146 @@
+package main
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/control"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+const ctlUsage = `usage: hyprboard ctl [flags] <command> [args]
+
+commands:
+  status                                     show the active window and layouts
+  apps                                       list remembered and pinned apps
+  forget <class>                             forget everything about an app
+  pin <class> <keyboard> <layout> [variant]  always use a layout for an app
+  unpin <class>                              remove the pins of an app
+  pause                                      stop switching layouts
+  resume                                     start switching layouts again
+  exclude <class pattern>                    never touch the layout in matching apps
+  unexclude <class pattern>                  remove an exclusion
+  exclusions                                 list exclusions
+  cache                                      dump the layout index cache
+
+flags:
+`
+
+func runCtl(args []string) error {
+	flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+	socket := flags.String("socket", getControlSocket(), "path to the control socket")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), ctlUsage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing command")
+	}
+
+	client := control.NewClient(*socket)
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	switch command {
+	case control.CommandStatus:
+		var status control.Status
+		if err := client.Do(command, commandArgs, &status); err != nil {
+			return err
+		}
+		printStatus(status)
+
+	case control.CommandApps:
+		var apps control.Apps
+		if err := client.Do(command, commandArgs, &apps); err != nil {
+			return err
+		}
+		printApps(apps)
+
+	case control.CommandCache:
+		var entries []control.CacheEntry
+		if err := client.Do(command, commandArgs, &entries); err != nil {
+			return err
+		}
+		printCache(entries)
+
+	case control.CommandExclusions:
+		var patterns []string
+		if err := client.Do(command, commandArgs, &patterns); err != nil {
+			return err
+		}
+		for _, pattern := range patterns {
+			fmt.Println(pattern)
+		}
+
+	default:
+		if err := client.Do(command, commandArgs, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printStatus(status control.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "paused:\t%t\n", status.Paused)
+	fmt.Fprintf(w, "class:\t%s\n", status.Window.Class)
+	fmt.Fprintf(w, "title:\t%s\n", status.Window.Title)
+	if status.Window.Address != "" {
+		fmt.Fprintf(w, "address:\t%s\n", status.Window.Address)
+	}
+	if status.Workspace != "" {
+		fmt.Fprintf(w, "workspace:\t%s\n", status.Workspace)
+	}
+	if status.Monitor != "" {
+		fmt.Fprintf(w, "monitor:\t%s\n", status.Monitor)
+	}
+	if status.EventErrors > 0 {
+		fmt.Fprintf(w, "skipped events:\t%d\n", status.EventErrors)
+	}
+	for _, keyboard := range sortedKeys(status.Layouts) {
+		fmt.Fprintf(w, "%s:\t%s\n", keyboard, status.Layouts[keyboard])
+	}
+}
+
+func printApps(apps control.Apps) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "APP\tKEYBOARD\tLAYOUT\tPINNED")
+	printAppLayouts(w, apps.Pinned, true)
+	printAppLayouts(w, apps.Remembered, false)
+}
+
+func printAppLayouts(w *tabwriter.Writer, apps map[string]map[string]control.Layout, pinned bool) {
+	for _, app := range sortedKeys(apps) {
+		for _, keyboard := range sortedKeys(apps[app]) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", app, keyboard, apps[app][keyboard], pinned)
+		}
+	}
+}
+
+func printCache(entries []control.CacheEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "KEYBOARD\tINDEX\tLAYOUT")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", entry.Keyboard, entry.Index, entry.Layout)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
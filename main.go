@@ -1,11 +1,13 @@
 package main
 
 import (
+	"codeberg.org/miketth/hyprboard/pkg/control"
 	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
 	"codeberg.org/miketth/hyprboard/pkg/hyprland"
 	"codeberg.org/miketth/hyprboard/pkg/layoutstore/json"
 	"codeberg.org/miketth/hyprboard/pkg/layoutstore/memory"
 	"codeberg.org/miketth/hyprboard/pkg/layoutstore/sqlite"
+	"codeberg.org/miketth/hyprboard/pkg/rules"
 	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
 	"context"
 	"errors"
@@ -16,17 +18,30 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
 	"path"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 )
 
 func main() {
-	err := run()
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "ctl":
+		err = runCtl(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "status":
+		err = runStatus(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "stats":
+		err = runStats(os.Args[2:])
+	default:
+		err = run()
+	}
 	if err != nil {
 		log.Fatalf("error: %+v", err)
 	}
@@ -38,11 +53,26 @@ func run() error {
 		return fmt.Errorf("get state file: %w", err)
 	}
 
-	evdevXmlPath := flag.String("evdev-xml-path", "/usr/share/X11/xkb/rules/evdev.xml", "path to evdev.xml")
+	evdevXmlPath := flag.String("evdev-xml-path", "", "path to evdev.xml, if empty it's merged from the extras and user files in the xkb search path")
 	stateFile := flag.String("state-file", stateFileDefault, "path to persist state, can be *.json (JSON), *.db (sqlite), or - (memory)")
+	saveDelay := flag.Duration("save-delay", json.DefaultDebounce, "how long to keep changes in memory before writing them to a JSON state file")
+	rulesFile := flag.String("rules-file", getRulesFile(), "path to JSON file with default layout rules, exclusions and keyboard settings, ignored if missing")
+	memoryMode := flag.String("memory", "class", "what to remember layouts for: class (per app), address (per window), workspace, monitor, or window (address,class); separate several with commas, most specific first")
+	controlSocket := flag.String("control-socket", getControlSocket(), "path to the control socket, empty to disable")
+	socketDir := flag.String("socket-dir", "", "directory with Hyprland instance directories, $XDG_RUNTIME_DIR/hypr and /tmp/hypr are searched if empty")
+	syncKeyboards := flag.Bool("sync-keyboards", false, "switch all keyboards to the layout the user switched to on one of them")
+	syncInclude := flag.String("sync-include", "", "comma separated keyboard names to sync, all keyboards if empty")
+	syncExclude := flag.String("sync-exclude", "", "comma separated keyboard names to never sync")
+	filterDevices := flag.Bool("filter-devices", true, "ignore keyboards that aren't used for typing, like power buttons and virtual keyboards")
+	strict := flag.Bool("strict", false, "exit on events that can't be processed instead of skipping them, for development")
 	debug := flag.Bool("debug", false, "enable debug logging")
 	flag.Parse()
 
+	scopes, err := parseMemoryMode(*memoryMode)
+	if err != nil {
+		return fmt.Errorf("parse memory mode: %w", err)
+	}
+
 	log, err := newLogger(*debug)
 	if err != nil {
 		return fmt.Errorf("create logger: %w", err)
@@ -52,23 +82,47 @@ func run() error {
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	registry, err := xkblayouts.ParseLayouts(*evdevXmlPath)
+	// Hyprland may report layout names in the user's language
+	translations, err := xkblayouts.LoadCatalog(xkblayouts.LocaleFromEnv(), "")
+	if err != nil {
+		log.Warnf("load layout name translations: %v", err)
+	}
+
+	registry, err := loadRegistry(*evdevXmlPath, xkblayouts.WithTranslations(translations))
 	if err != nil {
 		return fmt.Errorf("parse layouts: %w", err)
 	}
 
-	client, err := hyprland.Connect()
+	windowRules, err := loadRules(*rulesFile, registry)
+	if err != nil {
+		return fmt.Errorf("load rules: %w", err)
+	}
+
+	locator := hyprland.NewLocator(*socketDir)
+
+	var sw *hyprboard.Switcher
+	client, err := hyprland.Connect(locator, hyprland.WithReconnect(hyprland.ReconnectHooks{
+		OnAttempt: func(attempt int, err error) {
+			log.Warnf("lost connection to hyprland, reconnecting (attempt %d): %v", attempt, err)
+			_, _ = daemon.SdNotify(false, fmt.Sprintf("STATUS=Reconnecting to Hyprland (attempt %d)", attempt))
+		},
+		OnReconnect: func(attempts int) {
+			log.Infof("reconnected to hyprland after %d attempt(s)", attempts)
+			_, _ = daemon.SdNotify(false, readyStatus)
+			sw.InvalidateLayoutCache()
+		},
+	}))
 	if err != nil {
 		return fmt.Errorf("connect: %w", err)
 	}
 	defer client.Close()
 
-	hyprctl, err := hyprland.NewHyprctl()
+	hyprctl, err := hyprland.NewHyprctl(locator)
 	if err != nil {
 		return fmt.Errorf("connect hyprctl: %w", err)
 	}
 
-	layoutStore, err := createLayoutStore(ctx, *stateFile, log)
+	layoutStore, err := createLayoutStore(ctx, *stateFile, *saveDelay, log)
 	if err != nil {
 		return fmt.Errorf("create layout store: %w", err)
 	}
@@ -76,7 +130,45 @@ func run() error {
 		defer closer.Close()
 	}
 
-	sw := hyprboard.NewSwitcher(client, hyprctl, registry, layoutStore, log)
+	opts := []hyprboard.Option{hyprboard.WithScopes(scopes...)}
+	if *strict {
+		opts = append(opts, hyprboard.WithStrict())
+	}
+	if windowRules != nil {
+		opts = append(opts, hyprboard.WithDefaultLayouts(windowRules), hyprboard.WithDeviceAliases(windowRules.DeviceAliases()))
+	}
+	if *syncKeyboards {
+		opts = append(opts, hyprboard.WithKeyboardSync(splitList(*syncInclude), splitList(*syncExclude)))
+	}
+
+	sw = hyprboard.NewSwitcher(client, hyprctl, registry, layoutStore, log, opts...)
+
+	var ignoredDevices []string
+	if *filterDevices {
+		ignoredDevices = append(ignoredDevices, hyprboard.NonTypingDevices...)
+	}
+	if windowRules != nil {
+		for _, pattern := range windowRules.Exclusions() {
+			if err := sw.Exclude(pattern); err != nil {
+				return fmt.Errorf("exclude %q: %w", pattern, err)
+			}
+		}
+		ignoredDevices = append(ignoredDevices, windowRules.IgnoredDevices()...)
+	}
+	for _, pattern := range ignoredDevices {
+		if err := sw.IgnoreDevice(pattern); err != nil {
+			return fmt.Errorf("ignore device %q: %w", pattern, err)
+		}
+	}
+
+	var controlServer *control.Server
+	if *controlSocket != "" {
+		controlServer, err = control.Listen(*controlSocket, sw, log)
+		if err != nil {
+			return fmt.Errorf("listen on control socket: %w", err)
+		}
+		defer controlServer.Close()
+	}
 
 	log.Info("started hyprboard")
 
@@ -84,6 +176,17 @@ func run() error {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	if controlServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := controlServer.Serve(ctx)
+			if err != nil {
+				errChan <- fmt.Errorf("serve control socket: %w", err)
+			}
+		}()
+	}
+
 	go func() {
 		defer wg.Done()
 		err := sw.ProcessLines(ctx)
@@ -113,7 +216,7 @@ func run() error {
 	return nil
 }
 
-func createLayoutStore(ctx context.Context, filename string, log *zap.SugaredLogger) (hyprboard.ActiveLayoutStore, error) {
+func createLayoutStore(ctx context.Context, filename string, saveDelay time.Duration, log *zap.SugaredLogger) (hyprboard.ActiveLayoutStore, error) {
 	extension := path.Ext(filename)
 
 	var layoutStore hyprboard.ActiveLayoutStore
@@ -122,7 +225,7 @@ func createLayoutStore(ctx context.Context, filename string, log *zap.SugaredLog
 	case extension == ".db":
 		layoutStore, err = sqlite.NewLayoutStore(filename, log)
 	case extension == ".json":
-		layoutStore, err = json.NewLayoutStore(ctx, filename)
+		layoutStore, err = json.NewLayoutStore(ctx, filename, log, json.WithDebounce(saveDelay))
 	case filename == "-":
 		layoutStore = memory.NewLayoutStore()
 	default:
@@ -136,6 +239,68 @@ func createLayoutStore(ctx context.Context, filename string, log *zap.SugaredLog
 	return layoutStore, nil
 }
 
+func loadRegistry(evdevXmlPath string, opts ...xkblayouts.LoadOption) (*xkblayouts.XkbConfigRegistry, error) {
+	if evdevXmlPath != "" {
+		return xkblayouts.ParseLayouts(evdevXmlPath, opts...)
+	}
+
+	return xkblayouts.LoadRegistry("evdev", xkblayouts.SearchPaths(), opts...)
+}
+
+func loadRules(filename string, registry *xkblayouts.XkbConfigRegistry) (*rules.Rules, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	r, err := rules.Load(filename, registry)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// parseMemoryMode parses a comma separated list of scopes, most specific first.
+func parseMemoryMode(mode string) ([]hyprboard.Scope, error) {
+	var scopes []hyprboard.Scope
+	add := func(newScopes ...hyprboard.Scope) {
+		for _, scope := range newScopes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	for _, name := range strings.Split(mode, ",") {
+		switch scope := hyprboard.Scope(strings.TrimSpace(name)); scope {
+		case hyprboard.ScopeClass, hyprboard.ScopeAddress, hyprboard.ScopeWorkspace, hyprboard.ScopeMonitor:
+			add(scope)
+		case "window":
+			// per window, falling back to per app
+			add(hyprboard.ScopeAddress, hyprboard.ScopeClass)
+		default:
+			return nil, fmt.Errorf("unknown memory mode: %q", name)
+		}
+	}
+
+	return scopes, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+const readyStatus = "STATUS=Wildly switching keyboard layouts! 🤖"
+
 func systemdNotifyLoop(ctx context.Context) error {
 	// tell systemd that we're ready
 	supported, err := daemon.SdNotify(false, daemon.SdNotifyReady)
@@ -147,7 +312,7 @@ func systemdNotifyLoop(ctx context.Context) error {
 	}
 
 	// set funky message
-	_, _ = daemon.SdNotify(false, "STATUS=Wildly switching keyboard layouts! 🤖")
+	_, _ = daemon.SdNotify(false, readyStatus)
 
 	// notify watchdog
 	t, err := daemon.SdWatchdogEnabled(false)
@@ -182,6 +347,25 @@ func getStateFile() (string, error) {
 	return file, nil
 }
 
+func getControlSocket() string {
+	file, err := xdg.RuntimeFile("hyprboard/control.sock")
+	if err != nil {
+		return ""
+	}
+
+	return file
+}
+
+func getRulesFile() string {
+	file, err := xdg.SearchConfigFile("hyprboard/rules.json")
+	if err != nil {
+		// no rules file anywhere, point to where it would go
+		return path.Join(xdg.ConfigHome, "hyprboard/rules.json")
+	}
+
+	return file
+}
+
 func newLogger(debug bool) (*zap.SugaredLogger, error) {
 	loggerConfig := zap.NewDevelopmentConfig()
 
@@ -0,0 +1,98 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+type Client struct {
+	socketPath string
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// Do sends a command to the daemon, and decodes the response data into out, if it's not nil.
+func (c *Client) Do(command string, args []string, out any) error {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return fmt.Errorf("connect to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(Request{Command: command, Args: args}); err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	if out == nil || resp.Data == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe streams layout events from the daemon to handler, until ctx is done or the daemon goes away.
+func (c *Client) Subscribe(ctx context.Context, handler func(LayoutEvent) error) error {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return fmt.Errorf("connect to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	if err := json.NewEncoder(conn).Encode(Request{Command: CommandSubscribe}); err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp Response
+		err := dec.Decode(&resp)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return fmt.Errorf("read event: %w", err)
+		}
+
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+
+		var ev LayoutEvent
+		if err := json.Unmarshal(resp.Data, &ev); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+
+		if err := handler(ev); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package control
+
+import (
+	"encoding/json"
+)
+
+const (
+	CommandStatus = "status"
+	CommandApps   = "apps"
+	CommandForget = "forget"
+	CommandPin    = "pin"
+	CommandUnpin  = "unpin"
+	CommandPause  = "pause"
+	CommandResume = "resume"
+	CommandCache  = "cache"
+
+	CommandExclude    = "exclude"
+	CommandUnexclude  = "unexclude"
+	CommandExclusions = "exclusions"
+
+	// CommandSubscribe keeps the connection open and streams a response with a LayoutEvent for every change.
+	CommandSubscribe = "subscribe"
+)
+
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+type Response struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type Layout struct {
+	Code    string `json:"layout"`
+	Variant string `json:"variant,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Label   string `json:"label,omitempty"`
+}
+
+type Window struct {
+	Class   string `json:"class"`
+	Title   string `json:"title"`
+	Address string `json:"address,omitempty"`
+}
+
+type Status struct {
+	Paused    bool              `json:"paused"`
+	Window    Window            `json:"window"`
+	Workspace string            `json:"workspace,omitempty"`
+	Monitor   string            `json:"monitor,omitempty"`
+	Layouts   map[string]Layout `json:"layouts"`
+	// MainKeyboard is the keyboard a status bar should show, the one Hyprland considers the main one if it's known.
+	MainKeyboard string `json:"main_keyboard,omitempty"`
+	// EventErrors counts the events the daemon skipped because it couldn't process them.
+	EventErrors int `json:"event_errors"`
+}
+
+type Apps struct {
+	Remembered map[string]map[string]Layout `json:"remembered"`
+	Pinned     map[string]map[string]Layout `json:"pinned"`
+}
+
+type LayoutEvent struct {
+	Keyboard string `json:"keyboard"`
+	Layout   Layout `json:"layout"`
+	Class    string `json:"class"`
+	// Main is whether Keyboard is Status.MainKeyboard.
+	Main bool `json:"main"`
+}
+
+type CacheEntry struct {
+	Keyboard string `json:"keyboard"`
+	Layout   Layout `json:"layout"`
+	Index    int    `json:"index"`
+}
+
+func (l Layout) String() string {
+	if l.Variant == "" {
+		return l.Code
+	}
+	return l.Code + "(" + l.Variant + ")"
+}
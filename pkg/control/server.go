@@ -0,0 +1,270 @@
+package control
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+const requestTimeout = 5 * time.Second
+
+type Controller interface {
+	Status() hyprboard.Status
+	RememberedLayouts() (map[string]map[string]hyprboard.Layout, error)
+	Pins() map[string]map[string]hyprboard.Layout
+	Forget(class string) error
+	Pin(class string, keyboard string, layout hyprboard.Layout) error
+	Unpin(class string)
+	SetPaused(paused bool)
+	Exclude(pattern string) error
+	Unexclude(pattern string)
+	Exclusions() []string
+	LayoutCache() map[string]map[hyprboard.Layout]int
+	DescribeLayout(layout hyprboard.Layout) (string, string)
+	Subscribe() (<-chan hyprboard.LayoutEvent, func())
+}
+
+type Server struct {
+	listener   net.Listener
+	controller Controller
+	log        *zap.SugaredLogger
+}
+
+func Listen(socketPath string, controller Controller, log *zap.SugaredLogger) (*Server, error) {
+	// a socket left behind by a daemon that didn't exit cleanly is fine to replace, a live one isn't
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s is in use, is hyprboard already running?", socketPath)
+	}
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	return &Server{
+		listener:   listener,
+		controller: controller,
+		log:        log,
+	}, nil
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		_ = s.listener.Close()
+	}()
+
+	for {
+		conn, err := s.listener.Accept()
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		go s.handleConn(ctx, conn)
+	}
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		s.log.Debugf("decode control request: %v", err)
+		return
+	}
+
+	if req.Command == CommandSubscribe {
+		_ = conn.SetDeadline(time.Time{})
+		if err := s.stream(ctx, conn); err != nil {
+			s.log.Debugf("stream layout events: %v", err)
+		}
+		return
+	}
+
+	var resp Response
+	data, err := s.handle(req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else if data != nil {
+		resp.Data, err = json.Marshal(data)
+		if err != nil {
+			resp.Error = fmt.Sprintf("marshal response: %v", err)
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		s.log.Debugf("encode control response: %v", err)
+	}
+}
+
+func (s *Server) handle(req Request) (any, error) {
+	switch req.Command {
+	case CommandStatus:
+		status := s.controller.Status()
+		return Status{
+			Paused: status.Paused,
+			Window: Window{
+				Class:   status.Class,
+				Title:   status.Title,
+				Address: status.Address,
+			},
+			Workspace:    status.Workspace,
+			Monitor:      status.Monitor,
+			Layouts:      s.fromLayouts(status.Layouts),
+			MainKeyboard: status.MainKeyboard,
+			EventErrors:  status.EventErrors,
+		}, nil
+
+	case CommandApps:
+		remembered, err := s.controller.RememberedLayouts()
+		if err != nil {
+			return nil, err
+		}
+		return Apps{
+			Remembered: s.fromAppLayouts(remembered),
+			Pinned:     s.fromAppLayouts(s.controller.Pins()),
+		}, nil
+
+	case CommandForget:
+		if len(req.Args) != 1 {
+			return nil, fmt.Errorf("usage: %s <class>", req.Command)
+		}
+		return nil, s.controller.Forget(req.Args[0])
+
+	case CommandPin:
+		if len(req.Args) != 3 && len(req.Args) != 4 {
+			return nil, fmt.Errorf("usage: %s <class> <keyboard> <layout> [variant]", req.Command)
+		}
+		layout := hyprboard.Layout{Code: req.Args[2]}
+		if len(req.Args) == 4 {
+			layout.Variant = req.Args[3]
+		}
+		return nil, s.controller.Pin(req.Args[0], req.Args[1], layout)
+
+	case CommandUnpin:
+		if len(req.Args) != 1 {
+			return nil, fmt.Errorf("usage: %s <class>", req.Command)
+		}
+		s.controller.Unpin(req.Args[0])
+		return nil, nil
+
+	case CommandPause:
+		s.controller.SetPaused(true)
+		return nil, nil
+
+	case CommandResume:
+		s.controller.SetPaused(false)
+		return nil, nil
+
+	case CommandExclude:
+		if len(req.Args) != 1 {
+			return nil, fmt.Errorf("usage: %s <class pattern>", req.Command)
+		}
+		return nil, s.controller.Exclude(req.Args[0])
+
+	case CommandUnexclude:
+		if len(req.Args) != 1 {
+			return nil, fmt.Errorf("usage: %s <class pattern>", req.Command)
+		}
+		s.controller.Unexclude(req.Args[0])
+		return nil, nil
+
+	case CommandExclusions:
+		return s.controller.Exclusions(), nil
+
+	case CommandCache:
+		var entries []CacheEntry
+		for keyboard, layouts := range s.controller.LayoutCache() {
+			for layout, idx := range layouts {
+				entries = append(entries, CacheEntry{Keyboard: keyboard, Layout: s.fromLayout(layout), Index: idx})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Keyboard != entries[j].Keyboard {
+				return entries[i].Keyboard < entries[j].Keyboard
+			}
+			return entries[i].Index < entries[j].Index
+		})
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("unknown command: %q", req.Command)
+}
+
+func (s *Server) stream(ctx context.Context, conn net.Conn) error {
+	events, unsubscribe := s.controller.Subscribe()
+	defer unsubscribe()
+
+	// notice the client going away even if nothing happens for a while
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		unsubscribe()
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			data, err := json.Marshal(LayoutEvent{
+				Keyboard: ev.Keyboard,
+				Layout:   s.fromLayout(ev.Layout),
+				Class:    ev.Class,
+				Main:     ev.Main,
+			})
+			if err != nil {
+				return fmt.Errorf("marshal event: %w", err)
+			}
+
+			if err := enc.Encode(Response{Data: data}); err != nil {
+				return fmt.Errorf("write event: %w", err)
+			}
+		}
+	}
+}
+
+func (s *Server) fromLayout(layout hyprboard.Layout) Layout {
+	name, label := s.controller.DescribeLayout(layout)
+	return Layout{Code: layout.Code, Variant: layout.Variant, Name: name, Label: label}
+}
+
+func (s *Server) fromLayouts(layouts map[string]hyprboard.Layout) map[string]Layout {
+	ret := make(map[string]Layout, len(layouts))
+	for keyboard, layout := range layouts {
+		ret[keyboard] = s.fromLayout(layout)
+	}
+	return ret
+}
+
+func (s *Server) fromAppLayouts(apps map[string]map[string]hyprboard.Layout) map[string]map[string]Layout {
+	ret := make(map[string]map[string]Layout, len(apps))
+	for app, layouts := range apps {
+		ret[app] = s.fromLayouts(layouts)
+	}
+	return ret
+}
@@ -0,0 +1,144 @@
+package hyprboard
+
+import (
+	"fmt"
+)
+
+type Status struct {
+	Paused    bool
+	Class     string
+	Title     string
+	Address   string
+	Workspace string
+	Monitor   string
+	// Layouts are the currently active layouts per keyboard that isn't ignored, as far as we've seen.
+	Layouts map[string]Layout
+	// MainKeyboard is the keyboard Hyprland considers the main one, or the first in Layouts if it's not known.
+	MainKeyboard string
+	// EventErrors is how many events were skipped because they couldn't be processed.
+	EventErrors int
+}
+
+func (s *Switcher) Status() Status {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.findMainKeyboard()
+
+	layouts := make(map[string]Layout, len(s.currentLayouts))
+	for _, keyboard := range s.publishedKeyboards() {
+		layouts[keyboard] = s.currentLayouts[keyboard]
+	}
+
+	return Status{
+		Paused:       s.paused,
+		Class:        s.activeClass,
+		Title:        s.activeTitle,
+		Address:      s.activeAddress,
+		Workspace:    s.activeWorkspace,
+		Monitor:      s.activeMonitor,
+		Layouts:      layouts,
+		MainKeyboard: s.mainKeyboardName(),
+		EventErrors:  s.eventErrors,
+	}
+}
+
+// RememberedLayouts returns the layouts remembered per app.
+func (s *Switcher) RememberedLayouts() (map[string]map[string]Layout, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	layouts, err := s.activeLayouts.ListActiveLayouts(ScopeClass)
+	if err != nil {
+		return nil, fmt.Errorf("list active layouts: %w", err)
+	}
+
+	return layouts, nil
+}
+
+// Forget drops everything remembered and pinned for an app.
+func (s *Switcher) Forget(class string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pins, class)
+
+	if err := s.activeLayouts.ForgetActiveLayout(ScopeClass, class); err != nil {
+		return fmt.Errorf("forget active layout: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Switcher) Pins() map[string]map[string]Layout {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pins := make(map[string]map[string]Layout, len(s.pins))
+	for class, layouts := range s.pins {
+		pins[class] = make(map[string]Layout, len(layouts))
+		for keyboard, layout := range layouts {
+			pins[class][keyboard] = layout
+		}
+	}
+
+	return pins
+}
+
+// Pin makes an app always get a layout on a keyboard, regardless of what was last used in it.
+// Pins are kept until the daemon exits.
+func (s *Switcher) Pin(class string, keyboard string, layout Layout) error {
+	if s.possibleLayouts.GetLayoutPrettyName(layout.Code, layout.Variant) == "" {
+		return fmt.Errorf("%w (%q)", errLayoutNotFound, layout)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pins[class] == nil {
+		s.pins[class] = make(map[string]Layout)
+	}
+	s.pins[class][keyboard] = layout
+
+	if class != s.activeClass {
+		return nil
+	}
+
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) Unpin(class string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pins, class)
+}
+
+// SetPaused stops or resumes switching layouts on focus changes.
+func (s *Switcher) SetPaused(paused bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.paused = paused
+}
+
+// DescribeLayout returns the human-readable name and a short label for a layout.
+func (s *Switcher) DescribeLayout(layout Layout) (string, string) {
+	return s.possibleLayouts.GetLayoutPrettyName(layout.Code, layout.Variant),
+		s.possibleLayouts.GetShortLabel(layout.Code, layout.Variant)
+}
+
+func (s *Switcher) LayoutCache() map[string]map[Layout]int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cache := make(map[string]map[Layout]int, len(s.layoutIdxCache))
+	for device, layouts := range s.layoutIdxCache {
+		cache[device] = make(map[Layout]int, len(layouts))
+		for layout, idx := range layouts {
+			cache[device][layout] = idx
+		}
+	}
+
+	return cache
+}
@@ -0,0 +1,146 @@
+package hyprboard
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NonTypingDevices are name patterns of keyboards that aren't used for typing, like buttons, hotkeys
+// and the virtual keyboards of tools typing from scripts. Their layout isn't worth remembering.
+var NonTypingDevices = []string{
+	".*power-button.*",
+	".*sleep-button.*",
+	".*lid-switch.*",
+	".*video-bus.*",
+	".*-hotkeys",
+	".*-hid-events",
+	".*-wmi-.*",
+	".*-consumer-control",
+	".*-system-control",
+	".*-avrcp",
+	"virtual-keyboard.*",
+	".*ydotool.*",
+	".*wtype.*",
+}
+
+// WithDeviceAliases makes several keyboards share remembered layouts under a single name. The map
+// goes from the shared name to the names of the keyboards.
+func WithDeviceAliases(aliases map[string][]string) Option {
+	return func(s *Switcher) {
+		s.aliasDevices = aliases
+		s.aliases = make(map[string]string)
+		for alias, devices := range aliases {
+			for _, device := range devices {
+				s.aliases[device] = alias
+			}
+		}
+	}
+}
+
+// IgnoreDevice stops remembering and restoring the layout of keyboards whose name matches the pattern.
+// The pattern has to match the whole name.
+func (s *Switcher) IgnoreDevice(pattern string) error {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("compile pattern: %w", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.ignoredDevices[pattern] = re
+	return nil
+}
+
+func (s *Switcher) isIgnoredDevice(keyboard string) bool {
+	for _, re := range s.ignoredDevices {
+		if re.MatchString(keyboard) {
+			return true
+		}
+	}
+	return false
+}
+
+// logicalKeyboard is the name layouts of the keyboard are remembered under.
+func (s *Switcher) logicalKeyboard(keyboard string) string {
+	if alias, ok := s.aliases[keyboard]; ok {
+		return alias
+	}
+	return keyboard
+}
+
+// physicalKeyboards turns remembered layouts into the layouts of actual keyboards, expanding aliases
+// and dropping ignored keyboards. Layouts remembered for a keyboard itself win over its alias.
+func (s *Switcher) physicalKeyboards(layouts map[string]Layout) map[string]Layout {
+	physical := make(map[string]Layout, len(layouts))
+	for keyboard, layout := range layouts {
+		devices, ok := s.aliasDevices[keyboard]
+		if !ok {
+			continue
+		}
+
+		for _, device := range devices {
+			if _, ok := layouts[device]; !ok {
+				physical[device] = layout
+			}
+		}
+	}
+
+	for keyboard, layout := range layouts {
+		if _, ok := s.aliasDevices[keyboard]; !ok {
+			physical[keyboard] = layout
+		}
+	}
+
+	for keyboard := range physical {
+		if s.isIgnoredDevice(keyboard) {
+			delete(physical, keyboard)
+		}
+	}
+
+	return physical
+}
+
+// getKeyboards asks Hyprland for the keyboards, and notes which one is the main one.
+func (s *Switcher) getKeyboards() ([]Keyboard, error) {
+	keyboards, err := s.switcher.GetKeyboards()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keyboards {
+		if k.Main {
+			s.mainKeyboard = k.Name
+		}
+	}
+
+	return keyboards, nil
+}
+
+// findMainKeyboard asks Hyprland which keyboard is the main one, if it's not known yet.
+func (s *Switcher) findMainKeyboard() {
+	if s.mainKeyboard != "" {
+		return
+	}
+
+	if _, err := s.getKeyboards(); err != nil {
+		s.log.Debugf("get keyboards to find the main one: %v", err)
+	}
+}
+
+// mainKeyboardName returns the keyboard a status bar should show, the one Hyprland considers the main
+// one, or the first of the ones seen if it's not known or ignored.
+func (s *Switcher) mainKeyboardName() string {
+	if s.mainKeyboard != "" && !s.isIgnoredDevice(s.mainKeyboard) {
+		return s.mainKeyboard
+	}
+
+	main := ""
+	for keyboard := range s.currentLayouts {
+		if !s.isIgnoredDevice(keyboard) && (main == "" || keyboard < main) {
+			main = keyboard
+		}
+	}
+
+	return main
+}
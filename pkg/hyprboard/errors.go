@@ -0,0 +1,31 @@
+package hyprboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidEvent means an event couldn't be made sense of, e.g. it's malformed or names an unknown layout.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// EventError is an error processing a single event. If it's an ErrInvalidEvent, later events aren't
+// affected by it, so unless the switcher is strict, it's logged and the event is skipped.
+type EventError struct {
+	Line string
+	Err  error
+}
+
+func (e *EventError) Error() string {
+	return fmt.Sprintf("event %q: %v", e.Line, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// WithStrict makes ProcessLines return on the first invalid event, instead of skipping it.
+func WithStrict() Option {
+	return func(s *Switcher) {
+		s.strict = true
+	}
+}
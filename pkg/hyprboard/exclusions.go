@@ -0,0 +1,51 @@
+package hyprboard
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Exclude stops touching the layout of windows whose class matches the pattern, both when
+// focusing them and when the layout is changed in them. The pattern has to match the whole class.
+func (s *Switcher) Exclude(pattern string) error {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("compile pattern: %w", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.exclusions[pattern] = re
+	return nil
+}
+
+func (s *Switcher) Unexclude(pattern string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.exclusions, pattern)
+}
+
+func (s *Switcher) Exclusions() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	patterns := make([]string, 0, len(s.exclusions))
+	for pattern := range s.exclusions {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+func (s *Switcher) isExcluded(class string) bool {
+	for _, re := range s.exclusions {
+		if re.MatchString(class) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package hyprboard
 
+import "context"
+
 type EventListener interface {
-	ReadLine() (string, error)
+	// ReadLine returns the next event line, or ctx's error once it's done.
+	ReadLine(ctx context.Context) (string, error)
 }
 
 type KeyboardLayoutSwitcher interface {
@@ -9,10 +12,45 @@ type KeyboardLayoutSwitcher interface {
 	SwitchToLayout(keyboard string, idx int) error
 }
 
+type LayoutSwitch struct {
+	Keyboard string
+	Index    int
+}
+
+// BatchLayoutSwitcher can be implemented by a KeyboardLayoutSwitcher to switch several keyboards at once.
+// The returned errors are in the order of the switches, nil for the ones that succeeded, and the
+// returned error is only set if none of them could be done.
+type BatchLayoutSwitcher interface {
+	SwitchToLayouts(switches []LayoutSwitch) ([]error, error)
+}
+
 type Keyboard struct {
 	Name     string
 	Layouts  []string
 	Variants []string
+	Rules    string
+	Model    string
+	Options  []string
+	// ActiveKeymap is the name of the active layout, as in activelayout events.
+	ActiveKeymap string
+	// ActiveLayoutIndex is -1 if it's unknown.
+	ActiveLayoutIndex int
+	// Main is whether Hyprland considers this the main keyboard.
+	Main bool
+}
+
+// LayoutAt returns the layout at the index of the keyboard's layouts.
+func (k Keyboard) LayoutAt(idx int) (Layout, bool) {
+	if idx < 0 || idx >= len(k.Layouts) {
+		return Layout{}, false
+	}
+
+	variant := ""
+	if idx < len(k.Variants) {
+		variant = k.Variants[idx]
+	}
+
+	return Layout{Code: k.Layouts[idx], Variant: variant}, true
 }
 
 type Layout struct {
@@ -20,7 +58,56 @@ type Layout struct {
 	Variant string
 }
 
+// Scope tells what kind of key a remembered layout belongs to.
+type Scope string
+
+const (
+	// ScopeClass keys layouts by window class, shared by all windows of an app.
+	ScopeClass Scope = "class"
+	// ScopeAddress keys layouts by window address, so it's only valid while the window is open.
+	// These layouts are never stored, the switcher keeps them until the window closes.
+	ScopeAddress Scope = "address"
+	// ScopeWorkspace keys layouts by the name of the active workspace.
+	ScopeWorkspace Scope = "workspace"
+	// ScopeMonitor keys layouts by the name of the focused monitor.
+	ScopeMonitor Scope = "monitor"
+)
+
+// ActiveLayoutStore remembers layouts per scope key and keyboard. It's used from many goroutines.
+// GetActiveLayout returns nil for keys that have nothing remembered. Returned maps belong to the caller.
+// layoutstore.RunConformance checks implementations for this.
 type ActiveLayoutStore interface {
-	GetActiveLayout(window string) (map[string]Layout, error)
-	SetActiveLayout(window string, keyboard string, layout Layout) error
+	GetActiveLayout(scope Scope, key string) (map[string]Layout, error)
+	SetActiveLayout(scope Scope, key string, keyboard string, layout Layout) error
+	ForgetActiveLayout(scope Scope, key string) error
+	ListActiveLayouts(scope Scope) (map[string]map[string]Layout, error)
 }
+
+// SwitchSource tells why a keyboard ended up on a layout.
+type SwitchSource string
+
+const (
+	// SwitchSourceUser is a switch done by the user.
+	SwitchSourceUser SwitchSource = "user"
+	// SwitchSourceRestored is a switch done by us when a window was focused.
+	SwitchSourceRestored SwitchSource = "restored"
+	// SwitchSourceFocus isn't a switch, the layout was carried over to a newly focused window.
+	SwitchSourceFocus SwitchSource = "focus"
+	// SwitchSourceSynced is a switch done by us to follow a switch the user did on another keyboard.
+	SwitchSourceSynced SwitchSource = "synced"
+	// SwitchSourceStopped isn't a switch, hyprboard stopped, so what was used until it started again isn't known.
+	// It's recorded without an app, keyboard and layout.
+	SwitchSourceStopped SwitchSource = "stopped"
+)
+
+// LayoutHistory can be implemented by an ActiveLayoutStore to keep a log of layouts used in apps.
+type LayoutHistory interface {
+	RecordSwitch(app string, keyboard string, layout Layout, source SwitchSource) error
+}
+
+type DefaultLayoutProvider interface {
+	GetDefaultLayout(class, title string) map[string]Layout
+}
+
+// AllKeyboards can be used as the keyboard name in default layouts to apply to every keyboard.
+const AllKeyboards = "*"
@@ -0,0 +1,71 @@
+package hyprboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errKeyboardNotFound = errors.New("keyboard not found")
+	errLayoutNotFound   = errors.New("layout not found")
+)
+
+// InvalidateLayoutCache forgets layout indices, they have to be looked up again
+// e.g. after Hyprland was restarted with a different configuration.
+func (s *Switcher) InvalidateLayoutCache() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.invalidateLayoutCache()
+}
+
+func (s *Switcher) invalidateLayoutCache() {
+	s.layoutIdxCache = make(map[string]map[Layout]int)
+}
+
+func (s *Switcher) refreshLayoutCache() error {
+	keyboards, err := s.getKeyboards()
+	if err != nil {
+		return fmt.Errorf("get keyboards: %w", err)
+	}
+
+	cache := make(map[string]map[Layout]int, len(keyboards))
+	for _, k := range keyboards {
+		layouts := make(map[Layout]int, len(k.Layouts))
+		for i := range k.Layouts {
+			layout, _ := k.LayoutAt(i)
+			if _, ok := layouts[layout]; !ok {
+				layouts[layout] = i
+			}
+		}
+
+		cache[k.Name] = layouts
+	}
+
+	s.layoutIdxCache = cache
+	return nil
+}
+
+func (s *Switcher) getLayoutIndexForDevice(device string, layout Layout) (int, error) {
+	// get it from cache if possible
+	if idx, ok := s.layoutIdxCache[device][layout]; ok {
+		return idx, nil
+	}
+
+	// the keyboards may have changed since the cache was filled
+	if err := s.refreshLayoutCache(); err != nil {
+		return -1, err
+	}
+
+	layouts, ok := s.layoutIdxCache[device]
+	if !ok {
+		return -1, fmt.Errorf("%w (%q)", errKeyboardNotFound, device)
+	}
+
+	idx, ok := layouts[layout]
+	if !ok {
+		return -1, fmt.Errorf("%w (%q) for keyboard %q", errLayoutNotFound, layout, device)
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,58 @@
+package hyprboard
+
+import (
+	"time"
+)
+
+// pendingSwitchTimeout is how long we wait for Hyprland to echo back a switch we did.
+const pendingSwitchTimeout = 2 * time.Second
+
+type pendingSwitch struct {
+	layout   Layout
+	idx      int
+	deadline time.Time
+}
+
+func (s *Switcher) addPendingSwitch(keyboard string, layout Layout, idx int) {
+	s.pending[keyboard] = append(s.pending[keyboard], pendingSwitch{
+		layout:   layout,
+		idx:      idx,
+		deadline: time.Now().Add(pendingSwitchTimeout),
+	})
+}
+
+// takePendingSwitch reports whether the layout change is the echo of a switch we did. Hyprland
+// echoes switches in order, so the ones before the match were either skipped or overridden.
+func (s *Switcher) takePendingSwitch(keyboard string, layout Layout) bool {
+	now := time.Now()
+	pending := s.pending[keyboard]
+	for i, p := range pending {
+		if p.layout != layout || now.After(p.deadline) {
+			continue
+		}
+
+		s.log.Debugf("layout change on %s is our switch to index %d", keyboard, p.idx)
+
+		if rest := pending[i+1:]; len(rest) > 0 {
+			s.pending[keyboard] = rest
+		} else {
+			delete(s.pending, keyboard)
+		}
+		return true
+	}
+
+	// not ours, the user switched, and whatever we were waiting for is moot now
+	delete(s.pending, keyboard)
+	return false
+}
+
+// expectedLayout is the layout the keyboard is going to be on once our pending switches are done.
+func (s *Switcher) expectedLayout(keyboard string) (Layout, bool) {
+	pending := s.pending[keyboard]
+	if len(pending) > 0 && time.Now().Before(pending[len(pending)-1].deadline) {
+		return pending[len(pending)-1].layout, true
+	}
+
+	layout, ok := s.currentLayouts[keyboard]
+	return layout, ok
+}
@@ -0,0 +1,78 @@
+package hyprboard
+
+import "sort"
+
+const subscriberBufferSize = 64
+
+type LayoutEvent struct {
+	Keyboard string
+	Layout   Layout
+	Class    string
+	// Main is whether this is the keyboard a status bar should show, see Status.MainKeyboard.
+	Main bool
+}
+
+// Subscribe returns a channel that gets the current layout of every keyboard, and then every change
+// of layout or active window. Ignored keyboards are left out. Events are dropped if the subscriber
+// can't keep up. The returned function unsubscribes and closes the channel.
+func (s *Switcher) Subscribe() (<-chan LayoutEvent, func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.findMainKeyboard()
+
+	ch := make(chan LayoutEvent, max(subscriberBufferSize, len(s.currentLayouts)))
+	for _, keyboard := range s.publishedKeyboards() {
+		ch <- s.layoutEvent(keyboard, s.currentLayouts[keyboard])
+	}
+
+	s.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *Switcher) layoutEvent(keyboard string, layout Layout) LayoutEvent {
+	return LayoutEvent{Keyboard: keyboard, Layout: layout, Class: s.activeClass, Main: keyboard == s.mainKeyboardName()}
+}
+
+// publishedKeyboards returns the keyboards that aren't ignored, sorted by name.
+func (s *Switcher) publishedKeyboards() []string {
+	keyboards := make([]string, 0, len(s.currentLayouts))
+	for keyboard := range s.currentLayouts {
+		if !s.isIgnoredDevice(keyboard) {
+			keyboards = append(keyboards, keyboard)
+		}
+	}
+	sort.Strings(keyboards)
+
+	return keyboards
+}
+
+func (s *Switcher) publish(keyboard string, layout Layout) {
+	if s.isIgnoredDevice(keyboard) {
+		return
+	}
+
+	ev := s.layoutEvent(keyboard, layout)
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			s.log.Debugf("subscriber is too slow, dropping layout event")
+		}
+	}
+}
+
+func (s *Switcher) publishAll() {
+	for _, keyboard := range s.publishedKeyboards() {
+		s.publish(keyboard, s.currentLayouts[keyboard])
+	}
+}
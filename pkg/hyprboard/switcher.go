@@ -1,18 +1,47 @@
 package hyprboard
 
 import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprland/events"
 	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
 	"context"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
-	"strings"
+	"regexp"
+	"slices"
+	"sync"
 )
 
 type Switcher struct {
-	activeLayouts  ActiveLayoutStore
-	layoutIdxCache map[string]map[Layout]int
-	activeWindow   string
+	// lock guards everything below, as the switcher is also controlled from outside the event loop
+	lock sync.Mutex
+
+	activeLayouts   ActiveLayoutStore
+	history         LayoutHistory
+	layoutIdxCache  map[string]map[Layout]int
+	scopes          []Scope
+	defaults        DefaultLayoutProvider
+	activeClass     string
+	activeTitle     string
+	activeAddress   string
+	activeWorkspace string
+	activeMonitor   string
+	currentLayouts  map[string]Layout
+	mainKeyboard    string
+	windowLayouts   map[string]map[string]Layout
+	pending         map[string][]pendingSwitch
+	pins            map[string]map[string]Layout
+	exclusions      map[string]*regexp.Regexp
+	ignoredDevices  map[string]*regexp.Regexp
+	aliases         map[string]string
+	aliasDevices    map[string][]string
+	paused          bool
+	strict          bool
+	eventErrors     int
+	sync            bool
+	syncInclude     map[string]struct{}
+	syncExclude     map[string]struct{}
+	subscribers     map[chan LayoutEvent]struct{}
 
 	listener        EventListener
 	switcher        KeyboardLayoutSwitcher
@@ -26,143 +55,445 @@ func NewSwitcher(
 	possibleLayouts *xkblayouts.XkbConfigRegistry,
 	activeLayoutStore ActiveLayoutStore,
 	log *zap.SugaredLogger,
+	opts ...Option,
 ) *Switcher {
-	return &Switcher{
+	s := &Switcher{
 		activeLayouts:   activeLayoutStore,
+		history:         nil,
 		layoutIdxCache:  make(map[string]map[Layout]int),
-		activeWindow:    "",
+		scopes:          []Scope{ScopeClass},
+		defaults:        nil,
+		activeClass:     "",
+		activeTitle:     "",
+		activeAddress:   "",
+		activeWorkspace: "",
+		activeMonitor:   "",
+		currentLayouts:  make(map[string]Layout),
+		mainKeyboard:    "",
+		windowLayouts:   make(map[string]map[string]Layout),
+		pending:         make(map[string][]pendingSwitch),
+		pins:            make(map[string]map[string]Layout),
+		exclusions:      make(map[string]*regexp.Regexp),
+		ignoredDevices:  make(map[string]*regexp.Regexp),
+		aliases:         make(map[string]string),
+		aliasDevices:    make(map[string][]string),
+		paused:          false,
+		strict:          false,
+		eventErrors:     0,
+		sync:            false,
+		syncInclude:     nil,
+		syncExclude:     nil,
+		subscribers:     make(map[chan LayoutEvent]struct{}),
 		listener:        listener,
 		switcher:        switcher,
 		possibleLayouts: possibleLayouts,
 		log:             log,
 	}
+
+	if history, ok := activeLayoutStore.(LayoutHistory); ok {
+		s.history = history
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type Option func(s *Switcher)
+
+// WithScopes sets which scopes layouts are remembered in, in order of precedence when restoring.
+func WithScopes(scopes ...Scope) Option {
+	return func(s *Switcher) {
+		s.scopes = scopes
+	}
+}
+
+// WithDefaultLayouts sets where to get layouts for windows that have nothing remembered yet.
+func WithDefaultLayouts(defaults DefaultLayoutProvider) Option {
+	return func(s *Switcher) {
+		s.defaults = defaults
+	}
+}
+
+func (s *Switcher) usesScope(scope Scope) bool {
+	for _, sc := range s.scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// activeKey returns the key of the focused window in the given scope, and whether it's known.
+func (s *Switcher) activeKey(scope Scope) (string, bool) {
+	switch scope {
+	case ScopeClass:
+		// no window focused is a valid "class" too, it's what the desktop gets
+		return s.activeClass, true
+	case ScopeAddress:
+		return s.activeAddress, s.activeAddress != ""
+	case ScopeWorkspace:
+		return s.activeWorkspace, s.activeWorkspace != ""
+	case ScopeMonitor:
+		return s.activeMonitor, s.activeMonitor != ""
+	}
+	return "", false
 }
 
 func (s *Switcher) ProcessLines(ctx context.Context) error {
+	defer s.recordStop()
+
 	for {
-		resultCh := make(chan string)
-		errCh := make(chan error)
-		go func() {
-			line, err := s.listener.ReadLine()
-			if err != nil {
-				errCh <- err
-				return
+		line, err := s.listener.ReadLine(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			resultCh <- line
-		}()
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case line := <-resultCh:
-			err := s.processLine(line)
-			if err != nil {
-				return fmt.Errorf("process line: %w", err)
-			}
-		case err := <-errCh:
+			// only the connection going away is fatal
 			return fmt.Errorf("get line: %w", err)
 		}
+
+		s.lock.Lock()
+		err = s.processLine(line)
+		invalid := errors.Is(err, ErrInvalidEvent)
+		if invalid {
+			s.eventErrors++
+		}
+		s.lock.Unlock()
+
+		if err == nil {
+			continue
+		}
+
+		err = &EventError{Line: line, Err: err}
+		// failing to talk to Hyprland or the store isn't about the event, the next ones would fail too
+		if !invalid || s.strict {
+			return fmt.Errorf("process line: %w", err)
+		}
+		s.log.Warnf("skipping %v", err)
 	}
 }
 
 func (s *Switcher) processLine(line string) error {
-	fields := strings.Split(line, ">>")
-	if len(fields) < 2 {
-		return fmt.Errorf("invalid line: %q", line)
+	ev, err := events.Parse(line)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
 	}
 
-	evType := fields[0]
-	evData := fields[1]
-	switch evType {
-	case "activelayout":
-		return s.processLayoutChange(evData)
-	case "activewindow":
-		return s.processWindowChange(evData)
+	return s.processEvent(ev)
+}
+
+func (s *Switcher) processEvent(ev events.Event) error {
+	switch ev := ev.(type) {
+	case events.ActiveLayout:
+		return s.processLayoutChange(ev.Keyboard, ev.Layout)
+	case events.ActiveWindow:
+		return s.processWindowChange(ev.Class, ev.Title)
+	case events.ActiveWindowV2:
+		return s.processWindowAddressChange(ev.Address)
+	case events.CloseWindow:
+		return s.processWindowClose(ev.Address)
+	case events.Workspace:
+		return s.processWorkspaceChange(ev.Name)
+	case events.WorkspaceV2:
+		return s.processWorkspaceChange(ev.Name)
+	case events.FocusedMon:
+		return s.processMonitorChange(ev.Monitor, ev.Workspace)
+	case events.ConfigReloaded:
+		return s.processConfigReload()
 	}
 
 	return nil
 }
 
-func (s *Switcher) processLayoutChange(data string) error {
-	dataParts := strings.Split(data, ",")
-
-	if len(dataParts) < 2 {
-		return fmt.Errorf("invalid layout change data: %q", data)
+func (s *Switcher) processLayoutChange(keyboardName, layoutName string) error {
+	layout, err := s.resolveLayout(keyboardName, layoutName)
+	if err != nil {
+		return err
 	}
 
-	keyboardName := dataParts[0]
-	layoutName := strings.Join(dataParts[1:], ",")
+	s.currentLayouts[keyboardName] = layout
+	s.publish(keyboardName, layout)
 
-	// get layout code and variant code
-	layoutCode, variantCode := s.possibleLayouts.GetLayoutAndVariantFromPrettyName(layoutName)
-	if layoutCode == "" {
-		return fmt.Errorf("layout %q not found", layoutName)
+	// our own switch echoed back, the window we did it for may not even be active anymore
+	if s.takePendingSwitch(keyboardName, layout) {
+		return nil
 	}
 
-	layout := Layout{Code: layoutCode, Variant: variantCode}
+	if s.isIgnoredDevice(keyboardName) {
+		return nil
+	}
 
-	err := s.activeLayouts.SetActiveLayout(s.activeWindow, keyboardName, layout)
-	if err != nil {
-		return fmt.Errorf("save active layout: %w", err)
+	// the keyboard's layouts were changed, the indices may be off. Keyboards that aren't known yet are
+	// looked up when they're first switched.
+	if layouts, ok := s.layoutIdxCache[keyboardName]; ok {
+		if _, ok := layouts[layout]; !ok {
+			s.invalidateLayoutCache()
+		}
 	}
 
-	return nil
+	if err := s.rememberLayout(s.logicalKeyboard(keyboardName), layout, SwitchSourceUser); err != nil {
+		return err
+	}
+
+	return s.syncKeyboards(keyboardName, layout)
 }
 
-var (
-	errKeyboardNotFound = errors.New("keyboard not found")
-	errLayoutNotFound   = errors.New("layout not found")
-)
+// resolveLayout finds out the layout from its name in an activelayout event. If the name is unknown or
+// ambiguous, the keyboard is asked what it's on.
+func (s *Switcher) resolveLayout(keyboard, prettyName string) (Layout, error) {
+	entries := s.possibleLayouts.GetEntriesFromPrettyName(prettyName)
+	if len(entries) == 1 {
+		return Layout{Code: entries[0].Layout, Variant: entries[0].Variant}, nil
+	}
+
+	layout, err := s.layoutFromDevice(keyboard, entries)
+	if err == nil {
+		return layout, nil
+	}
 
-func (s *Switcher) getLayoutIndexForDevice(device string, layout Layout) (int, error) {
-	// get it from cache if possible
-	if idx, ok := s.layoutIdxCache[device][layout]; ok {
-		return idx, nil
+	if len(entries) > 0 {
+		s.log.Debugf("layout %q is ambiguous, using the preferred one: %v", prettyName, err)
+		return Layout{Code: entries[0].Layout, Variant: entries[0].Variant}, nil
 	}
 
-	// get device keyboards
-	keyboards, err := s.switcher.GetKeyboards()
+	return Layout{}, fmt.Errorf("%w: layout %q not found: %w", ErrInvalidEvent, prettyName, err)
+}
+
+func (s *Switcher) layoutFromDevice(keyboard string, candidates []xkblayouts.Entry) (Layout, error) {
+	keyboards, err := s.getKeyboards()
 	if err != nil {
-		return -1, fmt.Errorf("get keyboards: %w", err)
+		return Layout{}, fmt.Errorf("get keyboards: %w", err)
 	}
 
-	// find the keyboard
-	var keyboard Keyboard
 	for _, k := range keyboards {
-		if k.Name == device {
-			keyboard = k
+		if k.Name != keyboard {
+			continue
+		}
+
+		active, hasActive := k.LayoutAt(k.ActiveLayoutIndex)
+
+		// the name is unknown, all that can be done is to trust the keyboard
+		if len(candidates) == 0 {
+			if hasActive {
+				return active, nil
+			}
+			if len(k.Layouts) == 1 {
+				layout, _ := k.LayoutAt(0)
+				return layout, nil
+			}
+			return Layout{}, fmt.Errorf("keyboard %q doesn't tell its active layout", keyboard)
+		}
+
+		var matches []Layout
+		for i := range k.Layouts {
+			layout, _ := k.LayoutAt(i)
+			if isCandidate(layout, candidates) {
+				matches = append(matches, layout)
+			}
+		}
+
+		switch {
+		case len(matches) == 1:
+			return matches[0], nil
+		// the keyboard may already be on another layout, if it was switched again since the event
+		case hasActive && slices.Contains(matches, active):
+			return active, nil
+		}
+
+		return Layout{}, fmt.Errorf("%d of the layouts of keyboard %q match", len(matches), keyboard)
+	}
+
+	return Layout{}, fmt.Errorf("%w (%q)", errKeyboardNotFound, keyboard)
+}
+
+func isCandidate(layout Layout, candidates []xkblayouts.Entry) bool {
+	for _, c := range candidates {
+		if c.Layout == layout.Code && c.Variant == layout.Variant {
+			return true
 		}
 	}
-	if len(keyboard.Layouts) == 0 {
-		return -1, fmt.Errorf("%w (%q)", errKeyboardNotFound, device)
+	return false
+}
+
+// rememberLayout records a switch in the history and remembers the layout for the active window.
+func (s *Switcher) rememberLayout(keyboard string, layout Layout, source SwitchSource) error {
+	// the layout carries over to excluded windows, it's not their choice
+	if s.isExcluded(s.activeClass) {
+		return nil
+	}
+
+	s.recordSwitch(keyboard, layout, source)
+
+	for _, scope := range s.scopes {
+		key, ok := s.activeKey(scope)
+		if !ok {
+			continue
+		}
+
+		err := s.setScopedLayout(scope, key, keyboard, layout)
+		if err != nil {
+			return fmt.Errorf("save active layout for %s: %w", scope, err)
+		}
 	}
 
-	for i := range keyboard.Layouts {
-		thisLayout, thisVariant := keyboard.Layouts[i], keyboard.Variants[i]
-		if thisLayout == layout.Code && thisVariant == layout.Variant {
-			if s.layoutIdxCache[device] == nil {
-				s.layoutIdxCache[device] = make(map[Layout]int)
+	return nil
+}
+
+func (s *Switcher) processWindowChange(class, title string) error {
+	classChanged := s.activeClass != class
+	s.activeClass = class
+	s.activeTitle = title
+
+	if classChanged {
+		s.publishAll()
+		for keyboard, layout := range s.currentLayouts {
+			if !s.isIgnoredDevice(keyboard) {
+				s.recordSwitch(s.logicalKeyboard(keyboard), layout, SwitchSourceFocus)
 			}
+		}
+	}
 
-			s.layoutIdxCache[device][layout] = i
-			return i, nil
+	// activewindowv2 follows with the address, restore once we know it
+	if s.usesScope(ScopeAddress) {
+		return nil
+	}
+
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) processWindowAddressChange(data string) error {
+	if !s.usesScope(ScopeAddress) {
+		return nil
+	}
+
+	s.activeAddress = data
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) processWorkspaceChange(name string) error {
+	if !s.usesScope(ScopeWorkspace) || name == s.activeWorkspace {
+		return nil
+	}
+
+	s.activeWorkspace = name
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) processMonitorChange(monitor, workspace string) error {
+	changed := false
+	if s.usesScope(ScopeMonitor) && monitor != s.activeMonitor {
+		s.activeMonitor = monitor
+		changed = true
+	}
+	if s.usesScope(ScopeWorkspace) && workspace != s.activeWorkspace {
+		s.activeWorkspace = workspace
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) processWindowClose(data string) error {
+	if !s.usesScope(ScopeAddress) {
+		return nil
+	}
+
+	delete(s.windowLayouts, data)
+
+	return nil
+}
+
+func (s *Switcher) processConfigReload() error {
+	if err := s.refreshLayoutCache(); err != nil {
+		return fmt.Errorf("refresh layout cache: %w", err)
+	}
+
+	// reloading resets the keyboards, and the remembered layouts may not be there anymore
+	return s.restoreLayouts()
+}
+
+func (s *Switcher) getRememberedLayouts() (map[string]Layout, error) {
+	newLayout := make(map[string]Layout)
+	for device, layout := range s.pins[s.activeClass] {
+		newLayout[device] = layout
+	}
+
+	for _, scope := range s.scopes {
+		key, ok := s.activeKey(scope)
+		if !ok {
+			continue
+		}
+
+		layouts, err := s.getScopedLayouts(scope, key)
+		if err != nil {
+			return nil, fmt.Errorf("get active layout for %s: %w", scope, err)
+		}
+
+		// more specific scopes come first, only fill in what they didn't have
+		for device, layout := range layouts {
+			if _, ok := newLayout[device]; !ok {
+				newLayout[device] = layout
+			}
 		}
 	}
 
-	return -1, fmt.Errorf("%w (%q) for keyboard %q", errLayoutNotFound, layout, keyboard.Name)
+	return newLayout, nil
 }
 
-func (s *Switcher) processWindowChange(data string) error {
-	s.activeWindow = strings.Split(data, ",")[0]
-	newLayout, err := s.activeLayouts.GetActiveLayout(s.activeWindow)
+func (s *Switcher) expandAllKeyboards(layouts map[string]Layout) (map[string]Layout, error) {
+	allLayout, ok := layouts[AllKeyboards]
+	if !ok {
+		return layouts, nil
+	}
+
+	keyboards, err := s.getKeyboards()
 	if err != nil {
-		return fmt.Errorf("get active layout: %w", err)
+		return nil, fmt.Errorf("get keyboards: %w", err)
+	}
+
+	expanded := make(map[string]Layout, len(keyboards))
+	for _, k := range keyboards {
+		if layout, ok := layouts[k.Name]; ok {
+			expanded[k.Name] = layout
+		} else {
+			expanded[k.Name] = allLayout
+		}
 	}
-	if newLayout == nil {
+
+	return expanded, nil
+}
+
+func (s *Switcher) restoreLayouts() error {
+	if s.paused || s.isExcluded(s.activeClass) {
 		return nil
 	}
 
-	for device, layout := range newLayout {
+	newLayout, err := s.getRememberedLayouts()
+	if err != nil {
+		return err
+	}
+
+	if len(newLayout) == 0 && s.defaults != nil {
+		newLayout, err = s.expandAllKeyboards(s.defaults.GetDefaultLayout(s.activeClass, s.activeTitle))
+		if err != nil {
+			return fmt.Errorf("get default layouts: %w", err)
+		}
+	}
+
+	var planned []plannedSwitch
+	for device, layout := range s.physicalKeyboards(newLayout) {
+		// nothing to do, and this saves Hyprland from echoing it back to us
+		if current, ok := s.expectedLayout(device); ok && current == layout {
+			continue
+		}
+
 		idx, err := s.getLayoutIndexForDevice(device, layout)
 		switch {
 		case errors.Is(err, errKeyboardNotFound):
@@ -174,11 +505,83 @@ func (s *Switcher) processWindowChange(data string) error {
 			return fmt.Errorf("get layout index: %w", err)
 		}
 
-		if err := s.switcher.SwitchToLayout(device, idx); err != nil {
-			s.log.Warnf("switch layout: %v", err)
+		planned = append(planned, plannedSwitch{keyboard: device, layout: layout, idx: idx})
+	}
+
+	for _, p := range s.switchLayouts(planned) {
+		s.addPendingSwitch(p.keyboard, p.layout, p.idx)
+		s.recordSwitch(s.logicalKeyboard(p.keyboard), p.layout, SwitchSourceRestored)
+	}
+
+	return nil
+}
+
+type plannedSwitch struct {
+	keyboard string
+	layout   Layout
+	idx      int
+}
+
+// switchLayouts does the switches, in a single request if possible, and returns the ones that succeeded.
+func (s *Switcher) switchLayouts(planned []plannedSwitch) []plannedSwitch {
+	errs := make([]error, len(planned))
+
+	if batch, ok := s.switcher.(BatchLayoutSwitcher); ok && len(planned) > 1 {
+		switches := make([]LayoutSwitch, 0, len(planned))
+		for _, p := range planned {
+			switches = append(switches, LayoutSwitch{Keyboard: p.keyboard, Index: p.idx})
+		}
+
+		batchErrs, err := batch.SwitchToLayouts(switches)
+		for i := range errs {
+			if err != nil {
+				errs[i] = err
+			} else if i < len(batchErrs) {
+				errs[i] = batchErrs[i]
+			}
+		}
+	} else {
+		for i, p := range planned {
+			errs[i] = s.switcher.SwitchToLayout(p.keyboard, p.idx)
+		}
+	}
+
+	done := make([]plannedSwitch, 0, len(planned))
+	for i, p := range planned {
+		if errs[i] != nil {
+			s.log.Warnf("switch layout of %s: %v", p.keyboard, errs[i])
+			// the keyboard might be gone, look it up again next time
+			delete(s.layoutIdxCache, p.keyboard)
 			continue
 		}
+
+		done = append(done, p)
 	}
 
-	return nil
+	return done
+}
+
+// recordStop ends the time the last layouts were used for in the history.
+func (s *Switcher) recordStop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.history == nil {
+		return
+	}
+
+	if err := s.history.RecordSwitch("", "", Layout{}, SwitchSourceStopped); err != nil {
+		s.log.Warnf("record stop: %v", err)
+	}
+}
+
+func (s *Switcher) recordSwitch(keyboard string, layout Layout, source SwitchSource) {
+	if s.history == nil {
+		return
+	}
+
+	// the history is nice to have, not worth stopping for
+	if err := s.history.RecordSwitch(s.activeClass, keyboard, layout, source); err != nil {
+		s.log.Warnf("record layout switch: %v", err)
+	}
 }
@@ -0,0 +1,700 @@
+package hyprboard_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/hyprland"
+	"codeberg.org/miketth/hyprboard/pkg/hyprland/hyprlandtest"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/memory"
+	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const evdevXML = `<?xml version="1.0" encoding="UTF-8"?>
+<xkbConfigRegistry version="1.1">
+  <layoutList>
+    <layout>
+      <configItem>
+        <name>us</name>
+        <shortDescription>en</shortDescription>
+        <description>English (US)</description>
+      </configItem>
+      <variantList>
+        <variant>
+          <configItem>
+            <name>alt</name>
+            <description>English (US)</description>
+          </configItem>
+        </variant>
+      </variantList>
+    </layout>
+    <layout>
+      <configItem>
+        <name>hu</name>
+        <shortDescription>hu</shortDescription>
+        <description>Hungarian</description>
+      </configItem>
+    </layout>
+  </layoutList>
+</xkbConfigRegistry>
+`
+
+var (
+	kitty   = hyprlandtest.Window{Class: "kitty", Title: "~", Address: "0x1"}
+	firefox = hyprlandtest.Window{Class: "firefox", Title: "Mozilla Firefox", Address: "0x2"}
+	steam   = hyprlandtest.Window{Class: "steam", Title: "Steam", Address: "0x3"}
+)
+
+var (
+	usLayout = hyprlandtest.Layout{Code: "us", Name: "English (US)"}
+	huLayout = hyprlandtest.Layout{Code: "hu", Name: "Hungarian"}
+)
+
+// singleKeyboard returns a new keyboard list every time, as the server changes the keyboards in it.
+func singleKeyboard() []hyprlandtest.Keyboard {
+	return []hyprlandtest.Keyboard{{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true}}
+}
+
+// startSwitcher runs a switcher against a fake Hyprland with a keyboard that has English and Hungarian layouts,
+// and fails the test if it stops before the test is done.
+func startSwitcher(t *testing.T, store hyprboard.ActiveLayoutStore, opts ...hyprboard.Option) (*hyprlandtest.Server, *hyprboard.Switcher) {
+	t.Helper()
+
+	return startSwitcherWith(t, singleKeyboard(), store, opts...)
+}
+
+// startSwitcherWith is startSwitcher with the given keyboards.
+func startSwitcherWith(t *testing.T, keyboards []hyprlandtest.Keyboard, store hyprboard.ActiveLayoutStore, opts ...hyprboard.Option) (*hyprlandtest.Server, *hyprboard.Switcher) {
+	t.Helper()
+
+	server, switcher, done, cancel := runSwitcherWith(t, keyboards, store, opts...)
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil && !errors.Is(err, context.Canceled) {
+			t.Errorf("process lines: %v", err)
+		}
+	})
+
+	return server, switcher
+}
+
+// runSwitcher is startSwitcher for tests that expect it to stop, it returns what ProcessLines returns.
+func runSwitcher(t *testing.T, store hyprboard.ActiveLayoutStore, opts ...hyprboard.Option) (*hyprlandtest.Server, *hyprboard.Switcher, <-chan error, context.CancelFunc) {
+	t.Helper()
+
+	return runSwitcherWith(t, singleKeyboard(), store, opts...)
+}
+
+func runSwitcherWith(t *testing.T, keyboards []hyprlandtest.Keyboard, store hyprboard.ActiveLayoutStore, opts ...hyprboard.Option) (*hyprlandtest.Server, *hyprboard.Switcher, <-chan error, context.CancelFunc) {
+	t.Helper()
+
+	server := hyprlandtest.NewServer(t, keyboards...)
+
+	xmlPath := filepath.Join(t.TempDir(), "evdev.xml")
+	if err := os.WriteFile(xmlPath, []byte(evdevXML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := xkblayouts.ParseLayouts(xmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := hyprland.Connect(server.Locator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	hyprctl, err := hyprland.NewHyprctl(server.Locator())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switcher := hyprboard.NewSwitcher(client, hyprctl, registry, store, zap.NewNop().Sugar(), opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- switcher.ProcessLines(ctx) }()
+
+	return server, switcher, done, cancel
+}
+
+func switchRequests(server *hyprlandtest.Server) int {
+	n := 0
+	for _, r := range server.Requests() {
+		n += strings.Count(r, "switchxkblayout")
+	}
+	return n
+}
+
+// switchesOf returns the layout switches requested, without the flags.
+func switchesOf(server *hyprlandtest.Server) []string {
+	var switches []string
+	for _, r := range server.Requests() {
+		for _, request := range strings.Split(strings.TrimPrefix(r, "[[BATCH]]"), ";") {
+			if _, command, ok := strings.Cut(request, "/"); ok {
+				request = command
+			}
+			if strings.HasPrefix(request, "switchxkblayout") {
+				switches = append(switches, request)
+			}
+		}
+	}
+	return switches
+}
+
+func deviceRequests(server *hyprlandtest.Server) int {
+	n := 0
+	for _, r := range server.Requests() {
+		n += strings.Count(r, "devices")
+	}
+	return n
+}
+
+func TestRestoresLayoutOnFocus(t *testing.T) {
+	server, _ := startSwitcher(t, memory.NewLayoutStore())
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.WaitStep("kbd", 0),
+	)
+
+	if n := switchRequests(server); n != 2 {
+		t.Errorf("expected 2 layout switches, got %d: %q", n, server.Requests())
+	}
+}
+
+// historyStore keeps the switches the switcher records.
+type historyStore struct {
+	*memory.LayoutStore
+
+	lock     sync.Mutex
+	switches []string
+}
+
+func (h *historyStore) RecordSwitch(app string, keyboard string, layout hyprboard.Layout, source hyprboard.SwitchSource) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.switches = append(h.switches, fmt.Sprintf("%s %s %s %s", app, keyboard, layout.Code, source))
+	return nil
+}
+
+// waitFor waits for a switch to be recorded, and returns every switch recorded until then.
+func (h *historyStore) waitFor(t *testing.T, want string) []string {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		h.lock.Lock()
+		switches := append([]string(nil), h.switches...)
+		h.lock.Unlock()
+
+		if slices.Contains(switches, want) {
+			return switches
+		}
+	}
+
+	t.Fatalf("%q wasn't recorded", want)
+	return nil
+}
+
+func TestDoesNotRecordOwnSwitches(t *testing.T) {
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	server, _ := startSwitcher(t, history)
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		// Hyprland echoes the switch back, which must not look like the user did it
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.WaitStep("kbd", 0),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+	)
+
+	var user []string
+	for _, s := range history.waitFor(t, "firefox kbd hu user") {
+		if strings.HasSuffix(s, " user") {
+			user = append(user, s)
+		}
+	}
+
+	want := []string{"kitty kbd hu user", "firefox kbd us user", "firefox kbd hu user"}
+	if !slices.Equal(user, want) {
+		t.Errorf("expected user switches %q, got %q", want, user)
+	}
+}
+
+func TestSkipsExcludedWindows(t *testing.T) {
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	server, switcher := startSwitcher(t, history)
+	if err := switcher.Exclude("steam"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(steam),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.FocusStep(steam),
+		// switching back to kitty shows the switcher is done with steam
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+	)
+
+	remembered, err := switcher.RememberedLayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := remembered["steam"]; ok {
+		t.Errorf("expected nothing remembered for steam, got %v", remembered["steam"])
+	}
+	if n := switchRequests(server); n != 2 {
+		t.Errorf("expected 2 layout switches, got %d: %q", n, server.Requests())
+	}
+
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	if slices.Contains(history.switches, "steam kbd us user") {
+		t.Errorf("expected no switches recorded for steam, got %q", history.switches)
+	}
+}
+
+func TestFallsBackFromAddressToClass(t *testing.T) {
+	store := memory.NewLayoutStore()
+	server, _ := startSwitcher(t, store, hyprboard.WithScopes(hyprboard.ScopeAddress, hyprboard.ScopeClass))
+
+	kitty2 := hyprlandtest.Window{Class: "kitty", Title: "~", Address: "0x4"}
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		// a new kitty window gets what kitty had last
+		hyprlandtest.FocusStep(kitty2),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		// the first window has its own layout, even though kitty had another one since
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.FocusStep(kitty2),
+		hyprlandtest.WaitStep("kbd", 0),
+		// once it's closed, it's back to what kitty had last
+		hyprlandtest.EmitStep("closewindow>>1"),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 0),
+	)
+
+	addresses, err := store.ListActiveLayouts(hyprboard.ScopeAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 0 {
+		t.Errorf("expected no layouts per address in the store, got %v", addresses)
+	}
+}
+
+func TestLayersWorkspaceOverClass(t *testing.T) {
+	server, _ := startSwitcher(t, memory.NewLayoutStore(), hyprboard.WithScopes(hyprboard.ScopeWorkspace, hyprboard.ScopeClass))
+
+	server.Play(
+		hyprlandtest.EmitStep("workspace>>1"),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.EmitStep("workspace>>2"),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		// the workspace wins over what firefox had
+		hyprlandtest.EmitStep("workspace>>1"),
+		hyprlandtest.WaitStep("kbd", 1),
+		// a workspace with nothing remembered falls back to the class
+		hyprlandtest.EmitStep("workspace>>3"),
+		hyprlandtest.WaitStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+	)
+
+	if n := switchRequests(server); n != 3 {
+		t.Errorf("expected 3 layout switches, got %d: %q", n, server.Requests())
+	}
+}
+
+func TestKeepsLayoutCacheForKnownLayouts(t *testing.T) {
+	keyboards := []hyprlandtest.Keyboard{
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "power-button", Layouts: []hyprlandtest.Layout{huLayout}},
+	}
+	server, switcher := startSwitcherWith(t, keyboards, memory.NewLayoutStore())
+	if err := switcher.IgnoreDevice("power-button"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		// "English (US)" is ambiguous, the keyboard is asked every time
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		// the first restore fills the cache
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		// an ignored keyboard on a layout the cache doesn't know about
+		hyprlandtest.EmitStep("activelayout>>power-button,English (US)"),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.WaitStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+	)
+
+	// one for each "English (US)", and one to fill the cache
+	if n := deviceRequests(server); n != 4 {
+		t.Errorf("expected 4 device requests, got %d: %q", n, server.Requests())
+	}
+}
+
+func TestPublishesLayoutsOfTypingKeyboards(t *testing.T) {
+	keyboards := []hyprlandtest.Keyboard{
+		{Name: "power-button", Layouts: []hyprlandtest.Layout{huLayout}},
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "other", Layouts: []hyprlandtest.Layout{usLayout, huLayout}},
+	}
+	server, switcher := startSwitcherWith(t, keyboards, memory.NewLayoutStore())
+	if err := switcher.IgnoreDevice("power-button"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := switcher.Subscribe()
+	defer unsubscribe()
+
+	server.Play(
+		hyprlandtest.SwitchLayoutStep("power-button", 0),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.SwitchLayoutStep("other", 1),
+		hyprlandtest.FocusStep(kitty),
+	)
+
+	want := []string{
+		"kbd hu  main",
+		"other hu  ",
+		// a new window is a change for every keyboard, in order
+		"kbd hu kitty main",
+		"other hu kitty ",
+	}
+	var got []string
+	for len(got) < len(want) {
+		select {
+		case ev := <-events:
+			main := ""
+			if ev.Main {
+				main = "main"
+			}
+			got = append(got, fmt.Sprintf("%s %s %s %s", ev.Keyboard, ev.Layout.Code, ev.Class, main))
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected events %q, got %q", want, got)
+		}
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected events %q, got %q", want, got)
+	}
+
+	status := switcher.Status()
+	if _, ok := status.Layouts["power-button"]; ok || status.MainKeyboard != "kbd" {
+		t.Errorf("expected the status to show kbd and not the power button, got %+v", status)
+	}
+}
+
+// syncKeyboards returns keyboards that have the same layouts at different indices, and one that doesn't have hu.
+func syncKeyboards() []hyprlandtest.Keyboard {
+	return []hyprlandtest.Keyboard{
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "laptop", Layouts: []hyprlandtest.Layout{usLayout, huLayout}},
+		{Name: "other", Layouts: []hyprlandtest.Layout{huLayout, usLayout}, Active: 1},
+		{Name: "us-only", Layouts: []hyprlandtest.Layout{usLayout}},
+	}
+}
+
+func TestSyncsKeyboards(t *testing.T) {
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	server, _ := startSwitcherWith(t, syncKeyboards(), history, hyprboard.WithKeyboardSync(nil, []string{"laptop"}))
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.WaitStep("other", 0),
+		hyprlandtest.SwitchLayoutStep("other", 1),
+		hyprlandtest.WaitStep("kbd", 0),
+		// an excluded keyboard is neither synced from nor to
+		hyprlandtest.SwitchLayoutStep("laptop", 1),
+		// everything is on us already, except for laptop
+		hyprlandtest.SwitchLayoutStep("us-only", 0),
+	)
+
+	// every event before it was processed by then
+	switches := history.waitFor(t, "kitty us-only us user")
+
+	// us-only doesn't have hu, and the echoes of the synced switches aren't synced back
+	want := []string{"switchxkblayout other 0", "switchxkblayout kbd 0", "switchxkblayout us-only 0"}
+	if got := switchesOf(server); !slices.Equal(got, want) {
+		t.Errorf("expected switches %q, got %q", want, got)
+	}
+
+	for _, s := range []string{"kitty other hu synced", "kitty kbd us synced"} {
+		if !slices.Contains(switches, s) {
+			t.Errorf("expected %q to be recorded, got %q", s, switches)
+		}
+	}
+	if got := server.ActiveLayout("kbd"); got != 0 {
+		t.Errorf("expected kbd to stay on us, got layout %d", got)
+	}
+}
+
+func TestSyncsOnlyIncludedKeyboards(t *testing.T) {
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	server, _ := startSwitcherWith(t, syncKeyboards(), history, hyprboard.WithKeyboardSync([]string{"kbd", "other"}, nil))
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("laptop", 1),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.WaitStep("other", 0),
+		hyprlandtest.SwitchLayoutStep("us-only", 0),
+		hyprlandtest.SwitchLayoutStep("laptop", 0),
+	)
+
+	// every event before it was processed by then
+	history.waitFor(t, "kitty laptop us user")
+
+	want := []string{"switchxkblayout other 0"}
+	if got := switchesOf(server); !slices.Equal(got, want) {
+		t.Errorf("expected switches %q, got %q", want, got)
+	}
+}
+
+func TestSharesLayoutsBetweenAliasedKeyboards(t *testing.T) {
+	keyboards := []hyprlandtest.Keyboard{
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "laptop", Layouts: []hyprlandtest.Layout{usLayout, huLayout}},
+	}
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	// a keyboard's own layout wins over the one of its alias
+	for keyboard, layout := range map[string]hyprboard.Layout{"desk": {Code: "us"}, "laptop": {Code: "hu"}} {
+		if err := history.SetActiveLayout(hyprboard.ScopeClass, "firefox", keyboard, layout); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, _ := startSwitcherWith(t, keyboards, history, hyprboard.WithDeviceAliases(map[string][]string{
+		"desk": {"kbd", "laptop"},
+	}))
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.WaitStep("kbd", 0),
+		hyprlandtest.WaitStep("laptop", 1),
+		// what was switched on kbd is restored on every keyboard of the alias
+		hyprlandtest.SwitchLayoutStep("laptop", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.WaitStep("laptop", 1),
+	)
+
+	switches := history.waitFor(t, "kitty desk hu user")
+	for _, s := range switches {
+		if strings.Contains(s, " kbd ") || strings.Contains(s, " laptop ") {
+			t.Errorf("expected switches to be recorded for the alias, got %q", s)
+		}
+	}
+}
+
+func TestIgnoresDevices(t *testing.T) {
+	keyboards := []hyprlandtest.Keyboard{
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "power-button", Layouts: []hyprlandtest.Layout{usLayout, huLayout}},
+	}
+	history := &historyStore{LayoutStore: memory.NewLayoutStore()}
+	if err := history.SetActiveLayout(hyprboard.ScopeClass, "firefox", "power-button", hyprboard.Layout{Code: "hu"}); err != nil {
+		t.Fatal(err)
+	}
+	server, switcher := startSwitcherWith(t, keyboards, history)
+	if err := switcher.IgnoreDevice("power-.*"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("power-button", 1),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+	)
+
+	want := []string{"switchxkblayout kbd 1"}
+	if got := switchesOf(server); !slices.Equal(got, want) {
+		t.Errorf("expected switches %q, got %q", want, got)
+	}
+
+	remembered, err := switcher.RememberedLayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := remembered["kitty"]["power-button"]; ok {
+		t.Errorf("expected nothing remembered for the power button, got %v", remembered["kitty"])
+	}
+
+	for _, s := range history.waitFor(t, "kitty kbd hu restored") {
+		if strings.Contains(s, "power-button") {
+			t.Errorf("expected nothing recorded for the power button, got %q", s)
+		}
+	}
+}
+
+// classDefaults gives default layouts by class.
+type classDefaults map[string]map[string]hyprboard.Layout
+
+func (d classDefaults) GetDefaultLayout(class, _ string) map[string]hyprboard.Layout {
+	return d[class]
+}
+
+func TestExpandsDefaultsForAllKeyboards(t *testing.T) {
+	keyboards := []hyprlandtest.Keyboard{
+		{Name: "kbd", Layouts: []hyprlandtest.Layout{usLayout, huLayout}, Main: true},
+		{Name: "other", Layouts: []hyprlandtest.Layout{huLayout, usLayout}, Active: 1},
+	}
+	defaults := classDefaults{
+		"firefox": {hyprboard.AllKeyboards: {Code: "hu"}},
+		// a keyboard's own entry wins over the one for all of them
+		"kitty": {hyprboard.AllKeyboards: {Code: "us"}, "other": {Code: "hu"}},
+	}
+	server, _ := startSwitcherWith(t, keyboards, memory.NewLayoutStore(), hyprboard.WithDefaultLayouts(defaults))
+
+	server.Play(
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.WaitStep("kbd", 1),
+		hyprlandtest.WaitStep("other", 0),
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 0),
+	)
+
+	if got := server.ActiveLayout("other"); got != 0 {
+		t.Errorf("expected other to stay on hu, got layout %d", got)
+	}
+}
+
+func TestResolvesAmbiguousLayoutByName(t *testing.T) {
+	server, switcher := startSwitcher(t, memory.NewLayoutStore())
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		// "English (US)" is us and us(alt), the keyboard only has us, but it's already switched back to hu
+		hyprlandtest.EmitStep("activelayout>>kbd,English (US)"),
+	)
+
+	want := hyprboard.Layout{Code: "us"}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if switcher.Status().Layouts["kbd"] == want {
+			return
+		}
+	}
+	t.Errorf("expected kbd to be on %v, got %v", want, switcher.Status().Layouts["kbd"])
+}
+
+// waitForStop waits for ProcessLines to return.
+func waitForStop(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("the switcher didn't stop")
+		return nil
+	}
+}
+
+func TestSkipsInvalidEvents(t *testing.T) {
+	server, switcher := startSwitcher(t, memory.NewLayoutStore())
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+		hyprlandtest.EmitStep("activelayout>>kbd"),
+		hyprlandtest.FocusStep(firefox),
+		hyprlandtest.SwitchLayoutStep("kbd", 0),
+		// the events after the invalid one still work
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.WaitStep("kbd", 1),
+	)
+
+	if n := switcher.Status().EventErrors; n != 1 {
+		t.Errorf("expected 1 skipped event, got %d", n)
+	}
+}
+
+func TestStopsOnInvalidEventsWhenStrict(t *testing.T) {
+	server, _, done, _ := runSwitcher(t, memory.NewLayoutStore(), hyprboard.WithStrict())
+
+	server.Emit("activelayout>>kbd")
+
+	var eventErr *hyprboard.EventError
+	err := waitForStop(t, done)
+	if !errors.Is(err, hyprboard.ErrInvalidEvent) || !errors.As(err, &eventErr) || eventErr.Line != "activelayout>>kbd" {
+		t.Fatalf("expected an invalid event error, got %v", err)
+	}
+}
+
+var errStoreBroken = errors.New("store broken")
+
+type brokenStore struct {
+	*memory.LayoutStore
+}
+
+func (brokenStore) SetActiveLayout(hyprboard.Scope, string, string, hyprboard.Layout) error {
+	return errStoreBroken
+}
+
+func TestStopsOnStoreErrors(t *testing.T) {
+	server, _, done, _ := runSwitcher(t, brokenStore{LayoutStore: memory.NewLayoutStore()})
+
+	server.Play(
+		hyprlandtest.FocusStep(kitty),
+		hyprlandtest.SwitchLayoutStep("kbd", 1),
+	)
+
+	if err := waitForStop(t, done); !errors.Is(err, errStoreBroken) {
+		t.Fatalf("expected the store error, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package hyprboard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// WithKeyboardSync makes a layout change on one keyboard switch all the other keyboards to the same
+// layout, if they have it. If include isn't empty, only the keyboards in it are synced, and the ones
+// in exclude never are.
+func WithKeyboardSync(include, exclude []string) Option {
+	return func(s *Switcher) {
+		s.sync = true
+		s.syncInclude = toSet(include)
+		s.syncExclude = toSet(exclude)
+	}
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+func (s *Switcher) syncsKeyboard(keyboard string) bool {
+	if _, ok := s.syncExclude[keyboard]; ok {
+		return false
+	}
+	if len(s.syncInclude) == 0 {
+		return true
+	}
+	_, ok := s.syncInclude[keyboard]
+	return ok
+}
+
+// syncKeyboards switches the other keyboards to the layout the user switched to on one of them.
+// The switches are tracked as pending, so their echoes don't get synced again.
+func (s *Switcher) syncKeyboards(from string, layout Layout) error {
+	if !s.sync || s.paused || s.isExcluded(s.activeClass) || !s.syncsKeyboard(from) {
+		return nil
+	}
+
+	if len(s.layoutIdxCache) == 0 {
+		if err := s.refreshLayoutCache(); err != nil {
+			return fmt.Errorf("refresh layout cache: %w", err)
+		}
+	}
+
+	keyboards := make([]string, 0, len(s.layoutIdxCache))
+	for keyboard := range s.layoutIdxCache {
+		keyboards = append(keyboards, keyboard)
+	}
+	sort.Strings(keyboards)
+
+	var planned []plannedSwitch
+	for _, keyboard := range keyboards {
+		if keyboard == from || !s.syncsKeyboard(keyboard) || s.isIgnoredDevice(keyboard) {
+			continue
+		}
+
+		if current, ok := s.expectedLayout(keyboard); ok && current == layout {
+			continue
+		}
+
+		// keyboards without the layout are left alone, without asking Hyprland again for every one
+		if _, ok := s.layoutIdxCache[keyboard][layout]; !ok {
+			continue
+		}
+
+		idx, err := s.getLayoutIndexForDevice(keyboard, layout)
+		switch {
+		case errors.Is(err, errKeyboardNotFound), errors.Is(err, errLayoutNotFound):
+			continue
+		case err != nil:
+			return fmt.Errorf("get layout index: %w", err)
+		}
+
+		planned = append(planned, plannedSwitch{keyboard: keyboard, layout: layout, idx: idx})
+	}
+
+	for _, p := range s.switchLayouts(planned) {
+		s.addPendingSwitch(p.keyboard, p.layout, p.idx)
+		if err := s.rememberLayout(s.logicalKeyboard(p.keyboard), p.layout, SwitchSourceSynced); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package hyprboard
+
+import (
+	"maps"
+)
+
+// getScopedLayouts gets layouts from the store, except for window addresses. Those are only meaningful
+// until the window closes, and Hyprland reuses them across sessions, so they're kept in memory.
+func (s *Switcher) getScopedLayouts(scope Scope, key string) (map[string]Layout, error) {
+	if scope == ScopeAddress {
+		return maps.Clone(s.windowLayouts[key]), nil
+	}
+
+	return s.activeLayouts.GetActiveLayout(scope, key)
+}
+
+func (s *Switcher) setScopedLayout(scope Scope, key string, keyboard string, layout Layout) error {
+	if scope != ScopeAddress {
+		return s.activeLayouts.SetActiveLayout(scope, key, keyboard, layout)
+	}
+
+	if s.windowLayouts[key] == nil {
+		s.windowLayouts[key] = make(map[string]Layout)
+	}
+	s.windowLayouts[key][keyboard] = layout
+
+	return nil
+}
@@ -2,36 +2,228 @@ package hyprland
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"time"
 )
 
 var ErrNotRunning = errors.New("hyprland might not be running")
 
+const (
+	minReconnectDelay = 250 * time.Millisecond
+	maxReconnectDelay = 10 * time.Second
+)
+
 type Client struct {
+	// lock guards the fields below it, as a cancelled read interrupts the connection and Close can be
+	// called from another goroutine
+	lock   sync.Mutex
 	conn   net.Conn
 	reader *bufio.Reader
+	// done is closed by Close, to stop reconnecting
+	done chan struct{}
+	// watchedCtx is the context reads are interrupted for, it's usually the same for every read
+	watchedCtx context.Context
+	stopWatch  func() bool
+
+	locator   *Locator
+	reconnect bool
+	hooks     ReconnectHooks
+}
+
+type ReconnectHooks struct {
+	// OnAttempt is called before every reconnect attempt with the error that made it necessary.
+	OnAttempt func(attempt int, err error)
+	// OnReconnect is called after the connection is re-established.
+	OnReconnect func(attempts int)
+}
+
+type ConnectOption func(c *Client)
+
+// WithReconnect makes the client reconnect instead of failing when the connection drops,
+// e.g. because Hyprland was restarted.
+func WithReconnect(hooks ReconnectHooks) ConnectOption {
+	return func(c *Client) {
+		c.reconnect = true
+		c.hooks = hooks
+	}
 }
 
 func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.isClosed() {
+		return nil
+	}
+	close(c.done)
+
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+
 	return c.conn.Close()
 }
 
-func (c *Client) ReadLine() (string, error) {
-	str, err := c.reader.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("read from hypr socket: %w", err)
+// isClosed must be called with the lock held, unless it's fine to race with Close.
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) getReader() *bufio.Reader {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.reader
+}
+
+// ReadLine reads the next event line. It returns ctx's error once ctx is done, in which case the
+// line being read is lost, but the client can still be used.
+func (c *Client) ReadLine(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	c.watch(ctx)
+
+	for {
+		str, err := c.getReader().ReadString('\n')
+		if err == nil {
+			return strings.TrimSuffix(str, "\n"), nil
+		}
+
+		if ctx.Err() != nil {
+			c.clearDeadline()
+			return "", ctx.Err()
+		}
+
+		err = fmt.Errorf("read from hypr socket: %w", err)
+		if !c.reconnect || c.isClosed() {
+			return "", err
+		}
+
+		if err := c.reconnectWithBackoff(ctx, err); err != nil {
+			return "", err
+		}
+	}
+}
+
+// watch makes reads time out right away once ctx is done, as they can't be cancelled otherwise.
+func (c *Client) watch(ctx context.Context) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if ctx == c.watchedCtx || c.isClosed() {
+		return
+	}
+
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+
+	// the previous context may have been done between reads, leaving the deadline behind
+	_ = c.conn.SetReadDeadline(time.Time{})
+
+	c.watchedCtx = ctx
+	c.stopWatch = context.AfterFunc(ctx, func() { c.interrupt(ctx) })
+}
+
+func (c *Client) interrupt(ctx context.Context) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// reads may have moved on to another context while this was waiting for the lock
+	if ctx != c.watchedCtx {
+		return
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *Client) clearDeadline() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_ = c.conn.SetReadDeadline(time.Time{})
+}
+
+func (c *Client) reconnectWithBackoff(ctx context.Context, cause error) error {
+	c.lock.Lock()
+	_ = c.conn.Close()
+	c.lock.Unlock()
+
+	delay := minReconnectDelay
+	for attempt := 1; ; attempt++ {
+		if c.hooks.OnAttempt != nil {
+			c.hooks.OnAttempt(attempt, cause)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return fmt.Errorf("reconnect: %w", net.ErrClosed)
+		case <-time.After(delay):
+		}
+
+		conn, err := c.locator.connect(Socket2)
+		if err != nil {
+			cause = err
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+
+		if err := c.swapConn(conn); err != nil {
+			return err
+		}
+
+		// the read was interrupted on the old connection, not the new one
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if c.hooks.OnReconnect != nil {
+			c.hooks.OnReconnect(attempt)
+		}
+		return nil
 	}
-	return strings.TrimSuffix(str, "\n"), nil
 }
 
-func Connect() (*Client, error) {
-	conn, err := connect(Socket2)
+// swapConn starts using a new connection, unless the client was closed while it was being made.
+func (c *Client) swapConn(conn net.Conn) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.isClosed() {
+		_ = conn.Close()
+		return fmt.Errorf("reconnect: %w", net.ErrClosed)
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	return nil
+}
+
+func Connect(locator *Locator, opts ...ConnectOption) (*Client, error) {
+	conn, err := locator.connect(Socket2)
 	if err != nil {
 		return nil, fmt.Errorf("connect: %w", err)
 	}
 
-	return &Client{conn: conn, reader: bufio.NewReader(conn)}, nil
+	c := &Client{conn: conn, reader: bufio.NewReader(conn), done: make(chan struct{}), locator: locator}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
 }
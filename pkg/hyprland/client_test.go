@@ -0,0 +1,177 @@
+package hyprland
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	benchLine  = "activelayout>>at-translated-set-2-keyboard,English (US)\n"
+	benchBurst = 64
+)
+
+// startBursts makes a client read from a connection that gets n lines, in bursts like Hyprland sends
+// them on focus changes.
+func startBursts(b *testing.B, n int) *Client {
+	b.Helper()
+
+	server, conn := net.Pipe()
+	b.Cleanup(func() {
+		_ = server.Close()
+		_ = conn.Close()
+	})
+
+	burst := bytes.Repeat([]byte(benchLine), benchBurst)
+	go func() {
+		for sent := 0; sent < n; sent += benchBurst {
+			if _, err := server.Write(burst); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &Client{conn: conn, reader: bufio.NewReader(conn), done: make(chan struct{})}
+}
+
+func TestReadLineCancel(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn), done: make(chan struct{})}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := c.ReadLine(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the client still works after a cancelled read
+	go func() { _, _ = server.Write([]byte(benchLine)) }()
+
+	line, err := c.ReadLine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line+"\n" != benchLine {
+		t.Fatalf("unexpected line %q", line)
+	}
+
+	// and after a cancel between reads
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { _, _ = server.Write([]byte(benchLine + benchLine)) }()
+	if _, err := c.ReadLine(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	// let the cancel interrupt the connection
+	time.Sleep(10 * time.Millisecond)
+
+	// the second line is buffered already, read one that isn't
+	go func() { _, _ = server.Write([]byte(benchLine)) }()
+	for i := 0; i < 2; i++ {
+		if _, err := c.ReadLine(context.Background()); err != nil {
+			t.Fatalf("read after cancelling between reads: %v", err)
+		}
+	}
+}
+
+func BenchmarkReadLine(b *testing.B) {
+	c := startBursts(b, b.N)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ReadLine(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReadLineGoroutinePerLine reads the way the switcher used to, to compare against.
+func BenchmarkReadLineGoroutinePerLine(b *testing.B) {
+	c := startBursts(b, b.N)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resultCh := make(chan string)
+		errCh := make(chan error)
+		go func() {
+			line, err := c.ReadLine(ctx)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			resultCh <- line
+		}()
+
+		select {
+		case <-ctx.Done():
+			b.Fatal(ctx.Err())
+		case <-resultCh:
+		case err := <-errCh:
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCloseStopsReconnecting(t *testing.T) {
+	dir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "test")
+	if err := os.Mkdir(filepath.Join(dir, "test"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "test", ".socket2.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var c *Client
+	c, err = Connect(NewLocator(dir), WithReconnect(ReconnectHooks{
+		OnAttempt: func(attempt int, err error) {
+			if attempt == 1 {
+				_ = c.Close()
+			}
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the connection, like a Hyprland restart would
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = server.Close()
+
+	if _, err := c.ReadLine(context.Background()); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+
+	_ = listener.(*net.UnixListener).SetDeadline(time.Now().Add(2 * minReconnectDelay))
+	if conn, err := listener.Accept(); err == nil {
+		_ = conn.Close()
+		t.Fatal("reconnected after the client was closed")
+	}
+}
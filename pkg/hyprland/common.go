@@ -4,15 +4,63 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
+	"time"
 )
 
-func connect(sock socketType) (net.Conn, error) {
-	socketPath, err := getSocketPath(sock)
+type socketType int
+
+const (
+	Hyperctl socketType = iota
+	Socket2
+)
+
+const legacyHyprDir = "/tmp/hypr"
+
+// Locator finds the sockets of the running Hyprland instance.
+type Locator struct {
+	baseDirs []string
+}
+
+// NewLocator creates a Locator that looks for instances in socketDir, or in the
+// places Hyprland puts them ($XDG_RUNTIME_DIR/hypr, then /tmp/hypr) if it's empty.
+func NewLocator(socketDir string) *Locator {
+	if socketDir != "" {
+		return &Locator{baseDirs: []string{socketDir}}
+	}
+
+	var baseDirs []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		baseDirs = append(baseDirs, path.Join(runtimeDir, "hypr"))
+	}
+	baseDirs = append(baseDirs, legacyHyprDir)
+
+	return &Locator{baseDirs: baseDirs}
+}
+
+func (l *Locator) connect(sock socketType) (net.Conn, error) {
+	name, err := socketName(sock)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceDir, err := l.getInstanceDir()
 	if err != nil {
 		return nil, fmt.Errorf("get socket path: %w", err)
 	}
 
-	conn, err := net.Dial("unix", socketPath)
+	conn, err := net.Dial("unix", path.Join(instanceDir, name))
+	if err == nil {
+		return conn, nil
+	}
+
+	// a crashed instance leaves its socket behind, there may be a new one since
+	newestDir, newestErr := l.getNewestInstanceDir()
+	if newestErr != nil || newestDir == instanceDir {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	conn, err = net.Dial("unix", path.Join(newestDir, name))
 	if err != nil {
 		return nil, fmt.Errorf("dial: %w", err)
 	}
@@ -20,25 +68,78 @@ func connect(sock socketType) (net.Conn, error) {
 	return conn, nil
 }
 
-type socketType int
+func socketName(sock socketType) (string, error) {
+	switch sock {
+	case Hyperctl:
+		return ".socket.sock", nil
+	case Socket2:
+		return ".socket2.sock", nil
+	}
 
-const (
-	Hyperctl socketType = iota
-	Socket2
-)
+	return "", fmt.Errorf("unknown socket type: %d", sock)
+}
 
-func getSocketPath(sock socketType) (string, error) {
+// isInstanceDir tells if an instance directory has an event socket in it, the directory itself is left
+// behind if Hyprland crashes.
+func isInstanceDir(instanceDir string) bool {
+	_, err := os.Stat(path.Join(instanceDir, ".socket2.sock"))
+	return err == nil
+}
+
+func (l *Locator) getInstanceDir() (string, error) {
 	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
-	if signature == "" {
-		return "", fmt.Errorf("HYPRLAND_INSTANCE_SIGNATURE is not set, %w", ErrNotRunning)
+	if signature != "" {
+		for _, baseDir := range l.baseDirs {
+			instanceDir := path.Join(baseDir, signature)
+			if isInstanceDir(instanceDir) {
+				return instanceDir, nil
+			}
+		}
 	}
 
-	switch sock {
-	case Hyperctl:
-		return fmt.Sprintf("/tmp/hypr/%s/.socket.sock", signature), nil
-	case Socket2:
-		return fmt.Sprintf("/tmp/hypr/%s/.socket2.sock", signature), nil
+	// the signature is either not set (e.g. in a systemd unit), or stale
+	// because hyprland was restarted since we were started
+	instanceDir, err := l.getNewestInstanceDir()
+	switch {
+	case err != nil && signature == "":
+		return "", fmt.Errorf("HYPRLAND_INSTANCE_SIGNATURE is not set, %w", err)
+	case err != nil:
+		return "", fmt.Errorf("instance %q is gone: %w", signature, err)
 	}
 
-	return "", fmt.Errorf("unknown socket type: %d", sock)
+	return instanceDir, nil
+}
+
+func (l *Locator) getNewestInstanceDir() (string, error) {
+	var newest string
+	var newestTime time.Time
+	for _, baseDir := range l.baseDirs {
+		entries, err := os.ReadDir(baseDir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			instanceDir := path.Join(baseDir, entry.Name())
+			info, err := os.Stat(path.Join(instanceDir, ".socket2.sock"))
+			if err != nil {
+				continue
+			}
+
+			if info.ModTime().After(newestTime) {
+				newest = instanceDir
+				newestTime = info.ModTime()
+			}
+		}
+	}
+
+	if newest == "" {
+		return "", fmt.Errorf("no instances found in %v, %w", l.baseDirs, ErrNotRunning)
+	}
+
+	return newest, nil
 }
@@ -0,0 +1,90 @@
+package hyprland
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenInstance listens on the event socket of an instance in baseDir.
+func listenInstance(t *testing.T, baseDir, signature string) net.Listener {
+	t.Helper()
+
+	instanceDir := filepath.Join(baseDir, signature)
+	if err := os.MkdirAll(instanceDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(instanceDir, ".socket2.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return listener
+}
+
+// connectsTo checks that the locator connects to the listener.
+func connectsTo(t *testing.T, locator *Locator, listener net.Listener) {
+	t.Helper()
+
+	accepted := make(chan struct{})
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.Close()
+			close(accepted)
+		}
+	}()
+
+	conn, err := locator.connect(Socket2)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connected to another socket")
+	}
+}
+
+func TestSkipsLeftoverInstanceDir(t *testing.T) {
+	// unix socket paths are short, t.TempDir may be too long
+	baseDir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(baseDir) })
+
+	// what a crashed instance leaves behind
+	if err := os.Mkdir(filepath.Join(baseDir, "old"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	listener := listenInstance(t, baseDir, "new")
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "old")
+	connectsTo(t, NewLocator(baseDir), listener)
+}
+
+func TestSkipsLeftoverSocket(t *testing.T) {
+	baseDir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(baseDir) })
+
+	// a crashed instance doesn't remove its socket
+	old := listenInstance(t, baseDir, "old").(*net.UnixListener)
+	old.SetUnlinkOnClose(false)
+	_ = old.Close()
+
+	// the new socket has to be newer
+	time.Sleep(10 * time.Millisecond)
+	listener := listenInstance(t, baseDir, "new")
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "old")
+	connectsTo(t, NewLocator(baseDir), listener)
+}
@@ -0,0 +1,262 @@
+// Package events parses the events Hyprland sends on its event socket (socket2).
+package events
+
+// Event is a parsed event, one of the types in this package.
+type Event interface {
+	// Type is the name of the event on the socket, like "activewindow".
+	Type() string
+}
+
+const (
+	TypeWorkspace          = "workspace"
+	TypeWorkspaceV2        = "workspacev2"
+	TypeFocusedMon         = "focusedmon"
+	TypeFocusedMonV2       = "focusedmonv2"
+	TypeActiveWindow       = "activewindow"
+	TypeActiveWindowV2     = "activewindowv2"
+	TypeFullscreen         = "fullscreen"
+	TypeMonitorRemoved     = "monitorremoved"
+	TypeMonitorAdded       = "monitoradded"
+	TypeMonitorAddedV2     = "monitoraddedv2"
+	TypeCreateWorkspace    = "createworkspace"
+	TypeCreateWorkspaceV2  = "createworkspacev2"
+	TypeDestroyWorkspace   = "destroyworkspace"
+	TypeDestroyWorkspaceV2 = "destroyworkspacev2"
+	TypeMoveWorkspace      = "moveworkspace"
+	TypeMoveWorkspaceV2    = "moveworkspacev2"
+	TypeRenameWorkspace    = "renameworkspace"
+	TypeActiveSpecial      = "activespecial"
+	TypeActiveLayout       = "activelayout"
+	TypeOpenWindow         = "openwindow"
+	TypeCloseWindow        = "closewindow"
+	TypeMoveWindow         = "movewindow"
+	TypeMoveWindowV2       = "movewindowv2"
+	TypeWindowTitle        = "windowtitle"
+	TypeWindowTitleV2      = "windowtitlev2"
+	TypeOpenLayer          = "openlayer"
+	TypeCloseLayer         = "closelayer"
+	TypeSubmap             = "submap"
+	TypeChangeFloatingMode = "changefloatingmode"
+	TypeUrgent             = "urgent"
+	TypeMinimized          = "minimized"
+	TypeScreencast         = "screencast"
+	TypeConfigReloaded     = "configreloaded"
+	TypePin                = "pin"
+	TypeBell               = "bell"
+)
+
+// Workspace is sent when the active workspace changes.
+type Workspace struct {
+	Name string
+}
+
+type WorkspaceV2 struct {
+	ID   int
+	Name string
+}
+
+// FocusedMon is sent when the focused monitor changes.
+type FocusedMon struct {
+	Monitor   string
+	Workspace string
+}
+
+type FocusedMonV2 struct {
+	Monitor     string
+	WorkspaceID int
+}
+
+// ActiveWindow is sent when the focused window changes. Both fields are empty if no window is focused.
+type ActiveWindow struct {
+	Class string
+	Title string
+}
+
+type ActiveWindowV2 struct {
+	Address string
+}
+
+type Fullscreen struct {
+	Enabled bool
+}
+
+type MonitorRemoved struct {
+	Name string
+}
+
+type MonitorAdded struct {
+	Name string
+}
+
+type MonitorAddedV2 struct {
+	ID          int
+	Name        string
+	Description string
+}
+
+type CreateWorkspace struct {
+	Name string
+}
+
+type CreateWorkspaceV2 struct {
+	ID   int
+	Name string
+}
+
+type DestroyWorkspace struct {
+	Name string
+}
+
+type DestroyWorkspaceV2 struct {
+	ID   int
+	Name string
+}
+
+// MoveWorkspace is sent when a workspace is moved to another monitor.
+type MoveWorkspace struct {
+	Workspace string
+	Monitor   string
+}
+
+type MoveWorkspaceV2 struct {
+	WorkspaceID int
+	Workspace   string
+	Monitor     string
+}
+
+type RenameWorkspace struct {
+	ID      int
+	NewName string
+}
+
+// ActiveSpecial is sent when a special workspace is opened or closed on a monitor, Workspace is empty
+// when it's closed.
+type ActiveSpecial struct {
+	Workspace string
+	Monitor   string
+}
+
+// ActiveLayout is sent when the layout of a keyboard changes. Layout is its human-readable name.
+type ActiveLayout struct {
+	Keyboard string
+	Layout   string
+}
+
+type OpenWindow struct {
+	Address   string
+	Workspace string
+	Class     string
+	Title     string
+}
+
+type CloseWindow struct {
+	Address string
+}
+
+// MoveWindow is sent when a window is moved to another workspace.
+type MoveWindow struct {
+	Address   string
+	Workspace string
+}
+
+type MoveWindowV2 struct {
+	Address     string
+	WorkspaceID int
+	Workspace   string
+}
+
+type WindowTitle struct {
+	Address string
+}
+
+type WindowTitleV2 struct {
+	Address string
+	Title   string
+}
+
+type OpenLayer struct {
+	Namespace string
+}
+
+type CloseLayer struct {
+	Namespace string
+}
+
+// Submap is sent when the active keybind submap changes, Name is empty for the default one.
+type Submap struct {
+	Name string
+}
+
+type ChangeFloatingMode struct {
+	Address  string
+	Floating bool
+}
+
+type Urgent struct {
+	Address string
+}
+
+type Minimized struct {
+	Address   string
+	Minimized bool
+}
+
+type Screencast struct {
+	Active bool
+	// Owner is 0 for a monitor share, 1 for a window share.
+	Owner int
+}
+
+type ConfigReloaded struct{}
+
+type Pin struct {
+	Address string
+	Pinned  bool
+}
+
+type Bell struct {
+	Address string
+}
+
+// Unknown is an event this package doesn't know about, e.g. from a newer Hyprland.
+type Unknown struct {
+	Name string
+	Data string
+}
+
+func (Workspace) Type() string          { return TypeWorkspace }
+func (WorkspaceV2) Type() string        { return TypeWorkspaceV2 }
+func (FocusedMon) Type() string         { return TypeFocusedMon }
+func (FocusedMonV2) Type() string       { return TypeFocusedMonV2 }
+func (ActiveWindow) Type() string       { return TypeActiveWindow }
+func (ActiveWindowV2) Type() string     { return TypeActiveWindowV2 }
+func (Fullscreen) Type() string         { return TypeFullscreen }
+func (MonitorRemoved) Type() string     { return TypeMonitorRemoved }
+func (MonitorAdded) Type() string       { return TypeMonitorAdded }
+func (MonitorAddedV2) Type() string     { return TypeMonitorAddedV2 }
+func (CreateWorkspace) Type() string    { return TypeCreateWorkspace }
+func (CreateWorkspaceV2) Type() string  { return TypeCreateWorkspaceV2 }
+func (DestroyWorkspace) Type() string   { return TypeDestroyWorkspace }
+func (DestroyWorkspaceV2) Type() string { return TypeDestroyWorkspaceV2 }
+func (MoveWorkspace) Type() string      { return TypeMoveWorkspace }
+func (MoveWorkspaceV2) Type() string    { return TypeMoveWorkspaceV2 }
+func (RenameWorkspace) Type() string    { return TypeRenameWorkspace }
+func (ActiveSpecial) Type() string      { return TypeActiveSpecial }
+func (ActiveLayout) Type() string       { return TypeActiveLayout }
+func (OpenWindow) Type() string         { return TypeOpenWindow }
+func (CloseWindow) Type() string        { return TypeCloseWindow }
+func (MoveWindow) Type() string         { return TypeMoveWindow }
+func (MoveWindowV2) Type() string       { return TypeMoveWindowV2 }
+func (WindowTitle) Type() string        { return TypeWindowTitle }
+func (WindowTitleV2) Type() string      { return TypeWindowTitleV2 }
+func (OpenLayer) Type() string          { return TypeOpenLayer }
+func (CloseLayer) Type() string         { return TypeCloseLayer }
+func (Submap) Type() string             { return TypeSubmap }
+func (ChangeFloatingMode) Type() string { return TypeChangeFloatingMode }
+func (Urgent) Type() string             { return TypeUrgent }
+func (Minimized) Type() string          { return TypeMinimized }
+func (Screencast) Type() string         { return TypeScreencast }
+func (ConfigReloaded) Type() string     { return TypeConfigReloaded }
+func (Pin) Type() string                { return TypePin }
+func (Bell) Type() string               { return TypeBell }
+func (u Unknown) Type() string          { return u.Name }
@@ -0,0 +1,260 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed means an event doesn't look like Hyprland sends it.
+var ErrMalformed = errors.New("malformed")
+
+type parser func(data string) (Event, error)
+
+var parsers = map[string]parser{
+	TypeWorkspace: func(data string) (Event, error) {
+		return Workspace{Name: data}, nil
+	},
+	TypeWorkspaceV2: func(data string) (Event, error) {
+		id, name, err := idAndName(data)
+		return WorkspaceV2{ID: id, Name: name}, err
+	},
+	TypeFocusedMon: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		return FocusedMon{Monitor: f[0], Workspace: f[1]}, nil
+	},
+	TypeFocusedMonV2: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		id, err := atoi(f[1])
+		return FocusedMonV2{Monitor: f[0], WorkspaceID: id}, err
+	},
+	TypeActiveWindow: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		return ActiveWindow{Class: f[0], Title: f[1]}, nil
+	},
+	TypeActiveWindowV2: func(data string) (Event, error) {
+		return ActiveWindowV2{Address: data}, nil
+	},
+	TypeFullscreen: func(data string) (Event, error) {
+		enabled, err := parseBool(data)
+		return Fullscreen{Enabled: enabled}, err
+	},
+	TypeMonitorRemoved: func(data string) (Event, error) {
+		return MonitorRemoved{Name: data}, nil
+	},
+	TypeMonitorAdded: func(data string) (Event, error) {
+		return MonitorAdded{Name: data}, nil
+	},
+	TypeMonitorAddedV2: func(data string) (Event, error) {
+		f, err := split(data, 3)
+		if err != nil {
+			return nil, err
+		}
+		id, err := atoi(f[0])
+		return MonitorAddedV2{ID: id, Name: f[1], Description: f[2]}, err
+	},
+	TypeCreateWorkspace: func(data string) (Event, error) {
+		return CreateWorkspace{Name: data}, nil
+	},
+	TypeCreateWorkspaceV2: func(data string) (Event, error) {
+		id, name, err := idAndName(data)
+		return CreateWorkspaceV2{ID: id, Name: name}, err
+	},
+	TypeDestroyWorkspace: func(data string) (Event, error) {
+		return DestroyWorkspace{Name: data}, nil
+	},
+	TypeDestroyWorkspaceV2: func(data string) (Event, error) {
+		id, name, err := idAndName(data)
+		return DestroyWorkspaceV2{ID: id, Name: name}, err
+	},
+	TypeMoveWorkspace: func(data string) (Event, error) {
+		workspace, monitor, err := cutLast(data)
+		return MoveWorkspace{Workspace: workspace, Monitor: monitor}, err
+	},
+	TypeMoveWorkspaceV2: func(data string) (Event, error) {
+		rest, monitor, err := cutLast(data)
+		if err != nil {
+			return nil, err
+		}
+		id, name, err := idAndName(rest)
+		return MoveWorkspaceV2{WorkspaceID: id, Workspace: name, Monitor: monitor}, err
+	},
+	TypeRenameWorkspace: func(data string) (Event, error) {
+		id, name, err := idAndName(data)
+		return RenameWorkspace{ID: id, NewName: name}, err
+	},
+	TypeActiveSpecial: func(data string) (Event, error) {
+		workspace, monitor, err := cutLast(data)
+		return ActiveSpecial{Workspace: workspace, Monitor: monitor}, err
+	},
+	TypeActiveLayout: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		return ActiveLayout{Keyboard: f[0], Layout: f[1]}, nil
+	},
+	TypeOpenWindow: func(data string) (Event, error) {
+		// a workspace name with a comma can't be told apart from the class, but that's rare
+		f, err := split(data, 4)
+		if err != nil {
+			return nil, err
+		}
+		return OpenWindow{Address: f[0], Workspace: f[1], Class: f[2], Title: f[3]}, nil
+	},
+	TypeCloseWindow: func(data string) (Event, error) {
+		return CloseWindow{Address: data}, nil
+	},
+	TypeMoveWindow: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		return MoveWindow{Address: f[0], Workspace: f[1]}, nil
+	},
+	TypeMoveWindowV2: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		id, name, err := idAndName(f[1])
+		return MoveWindowV2{Address: f[0], WorkspaceID: id, Workspace: name}, err
+	},
+	TypeWindowTitle: func(data string) (Event, error) {
+		return WindowTitle{Address: data}, nil
+	},
+	TypeWindowTitleV2: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		return WindowTitleV2{Address: f[0], Title: f[1]}, nil
+	},
+	TypeOpenLayer: func(data string) (Event, error) {
+		return OpenLayer{Namespace: data}, nil
+	},
+	TypeCloseLayer: func(data string) (Event, error) {
+		return CloseLayer{Namespace: data}, nil
+	},
+	TypeSubmap: func(data string) (Event, error) {
+		return Submap{Name: data}, nil
+	},
+	TypeChangeFloatingMode: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		floating, err := parseBool(f[1])
+		return ChangeFloatingMode{Address: f[0], Floating: floating}, err
+	},
+	TypeUrgent: func(data string) (Event, error) {
+		return Urgent{Address: data}, nil
+	},
+	TypeMinimized: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		minimized, err := parseBool(f[1])
+		return Minimized{Address: f[0], Minimized: minimized}, err
+	},
+	TypeScreencast: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		active, err := parseBool(f[0])
+		if err != nil {
+			return nil, err
+		}
+		owner, err := atoi(f[1])
+		return Screencast{Active: active, Owner: owner}, err
+	},
+	TypeConfigReloaded: func(string) (Event, error) {
+		return ConfigReloaded{}, nil
+	},
+	TypePin: func(data string) (Event, error) {
+		f, err := split(data, 2)
+		if err != nil {
+			return nil, err
+		}
+		pinned, err := parseBool(f[1])
+		return Pin{Address: f[0], Pinned: pinned}, err
+	},
+	TypeBell: func(data string) (Event, error) {
+		return Bell{Address: data}, nil
+	},
+}
+
+// Parse parses a line from the event socket, without the trailing newline. Events this package
+// doesn't know are returned as Unknown.
+func Parse(line string) (Event, error) {
+	name, data, ok := strings.Cut(line, ">>")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("%w: no event type", ErrMalformed)
+	}
+
+	parse, ok := parsers[name]
+	if !ok {
+		return Unknown{Name: name, Data: data}, nil
+	}
+
+	ev, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return ev, nil
+}
+
+// split splits data into n fields. Only the last field can have commas in it, like window titles.
+func split(data string, n int) ([]string, error) {
+	fields := strings.SplitN(data, ",", n)
+	if len(fields) != n {
+		return nil, fmt.Errorf("%w: %d fields instead of %d", ErrMalformed, len(fields), n)
+	}
+	return fields, nil
+}
+
+// cutLast splits off the last field, for events where the one before it can have commas in it.
+func cutLast(data string) (string, string, error) {
+	i := strings.LastIndex(data, ",")
+	if i < 0 {
+		return "", "", fmt.Errorf("%w: 1 field instead of 2", ErrMalformed)
+	}
+	return data[:i], data[i+1:], nil
+}
+
+func idAndName(data string) (int, string, error) {
+	f, err := split(data, 2)
+	if err != nil {
+		return 0, "", err
+	}
+
+	id, err := atoi(f[0])
+	return id, f[1], err
+}
+
+func atoi(s string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return i, nil
+}
+
+// parseBool treats any number other than 0 as true, as some flags grew more states over time.
+func parseBool(s string) (bool, error) {
+	i, err := atoi(s)
+	return i != 0, err
+}
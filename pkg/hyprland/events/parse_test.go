@@ -0,0 +1,122 @@
+package events_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprland/events"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want events.Event
+	}{
+		// commas in titles and names stay in the field that can have them
+		{line: "activewindow>>firefox,Re: a, b and c - Mozilla Firefox", want: events.ActiveWindow{Class: "firefox", Title: "Re: a, b and c - Mozilla Firefox"}},
+		{line: "activewindow>>,", want: events.ActiveWindow{}},
+		{line: "windowtitlev2>>5f4c1a2b3c4d,vim main.go, util.go", want: events.WindowTitleV2{Address: "5f4c1a2b3c4d", Title: "vim main.go, util.go"}},
+		{line: "openwindow>>5f4c1a2b3c4d,2,kitty,~/a,b", want: events.OpenWindow{Address: "5f4c1a2b3c4d", Workspace: "2", Class: "kitty", Title: "~/a,b"}},
+		{line: "moveworkspacev2>>3,mail, chat,DP-1", want: events.MoveWorkspaceV2{WorkspaceID: 3, Workspace: "mail, chat", Monitor: "DP-1"}},
+		{line: "moveworkspace>>mail, chat,DP-1", want: events.MoveWorkspace{Workspace: "mail, chat", Monitor: "DP-1"}},
+		{line: "activelayout>>at-translated-set-2-keyboard,English (US, intl., with dead keys)", want: events.ActiveLayout{Keyboard: "at-translated-set-2-keyboard", Layout: "English (US, intl., with dead keys)"}},
+		{line: "workspacev2>>2,web", want: events.WorkspaceV2{ID: 2, Name: "web"}},
+		{line: "fullscreen>>2", want: events.Fullscreen{Enabled: true}},
+		{line: "configreloaded>>", want: events.ConfigReloaded{}},
+		// events added to Hyprland later
+		{line: "newevent>>a,b", want: events.Unknown{Name: "newevent", Data: "a,b"}},
+		{line: "newevent>>", want: events.Unknown{Name: "newevent"}},
+	}
+
+	for _, tt := range tests {
+		got, err := events.Parse(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %#v, got %#v", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"activewindow",
+		">>kitty,~",
+		"activewindow>>kitty",
+		"activelayout>>kbd",
+		"workspacev2>>web",
+		"workspacev2>>x,web",
+		"moveworkspacev2>>3,DP-1",
+		"moveworkspace>>mail",
+		"openwindow>>5f4c1a2b3c4d,2,kitty",
+		"screencast>>1",
+		"fullscreen>>yes",
+	} {
+		ev, err := events.Parse(line)
+		if !errors.Is(err, events.ErrMalformed) {
+			t.Errorf("%q: expected ErrMalformed, got %#v (%v)", line, ev, err)
+		}
+	}
+}
+
+// lineReader returns the lines, then blocks until the context is done.
+type lineReader struct {
+	lines chan string
+}
+
+func (r lineReader) ReadLine(ctx context.Context) (string, error) {
+	select {
+	case line := <-r.lines:
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func receive(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+
+	select {
+	case ev := <-sub.C:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	r := lineReader{lines: make(chan string, 4)}
+	r.lines <- "activewindow>>kitty,~"
+	r.lines <- "activelayout>>kbd,Hungarian"
+	r.lines <- "garbage"
+	r.lines <- "workspace>>2"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := events.Subscribe(ctx, r, events.Types(events.TypeActiveLayout))
+
+	if ev := receive(t, sub); ev != (events.ActiveLayout{Keyboard: "kbd", Layout: "Hungarian"}) {
+		t.Errorf("expected the activelayout event, got %#v", ev)
+	}
+
+	// malformed lines get through regardless of the filter
+	ev := receive(t, sub)
+	if malformed, ok := ev.(events.Malformed); !ok || malformed.Line != "garbage" || !errors.Is(malformed.Err, events.ErrMalformed) {
+		t.Errorf("expected the malformed line, got %#v", ev)
+	}
+
+	cancel()
+
+	if ev, ok := <-sub.C; ok {
+		t.Errorf("expected the subscription to be closed, got %#v", ev)
+	}
+	if err := sub.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+)
+
+const subscriptionBufferSize = 64
+
+// LineReader reads lines from the event socket, like hyprland.Client.
+type LineReader interface {
+	ReadLine(ctx context.Context) (string, error)
+}
+
+// Filter decides whether an event is sent to a subscriber.
+type Filter func(Event) bool
+
+// Types lets only events of the given types through.
+func Types(types ...string) Filter {
+	set := make(map[string]struct{}, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+
+	return func(ev Event) bool {
+		_, ok := set[ev.Type()]
+		return ok
+	}
+}
+
+// Malformed is sent to subscribers for lines that couldn't be parsed, so they can decide what to do.
+type Malformed struct {
+	Line string
+	Err  error
+}
+
+// Type is empty, it's not an event Hyprland sends.
+func (Malformed) Type() string { return "" }
+
+type Subscription struct {
+	// C gets the events, it's closed when reading fails or the context is done.
+	C   <-chan Event
+	err error
+}
+
+// Err tells why C was closed, it must only be called after that.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Subscribe reads and parses lines from r in the background, and sends the events the filter lets
+// through on the subscription's channel. Malformed lines are always sent. A nil filter lets everything through.
+func Subscribe(ctx context.Context, r LineReader, filter Filter) *Subscription {
+	ch := make(chan Event, subscriptionBufferSize)
+	sub := &Subscription{C: ch}
+
+	go func() {
+		defer close(ch)
+
+		for {
+			line, err := r.ReadLine(ctx)
+			if err != nil {
+				sub.err = err
+				return
+			}
+
+			var ev Event
+			ev, err = Parse(line)
+			if err != nil {
+				ev = Malformed{Line: line, Err: err}
+			} else if filter != nil && !filter(ev) {
+				continue
+			}
+
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				sub.err = ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return sub
+}
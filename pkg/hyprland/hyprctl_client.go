@@ -3,49 +3,81 @@ package hyprland
 import (
 	"bytes"
 	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strings"
+	"time"
 )
 
-type Hyprctl struct{}
+const defaultRequestTimeout = 2 * time.Second
 
-func NewHyprctl() (*Hyprctl, error) {
-	return &Hyprctl{}, nil
+// ErrUnknownRequest is returned for requests Hyprland doesn't know, e.g. because it's too old.
+var ErrUnknownRequest = errors.New("unknown request")
+
+// ResponseError is returned when Hyprland doesn't reply "ok" to a command.
+type ResponseError struct {
+	Request  string
+	Response string
 }
 
-func (c *Hyprctl) SwitchToLayout(keyboard string, idx int) error {
-	conn, err := c.makeRequest(fmt.Sprintf("switchxkblayout %s %d", keyboard, idx), "")
-	if err != nil {
-		return err
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("hyprctl %q: %s", e.Request, e.Response)
+}
+
+type Hyprctl struct {
+	locator *Locator
+	timeout time.Duration
+}
+
+type HyprctlOption func(c *Hyprctl)
+
+// WithRequestTimeout sets how long a request can take if its context doesn't have a deadline.
+func WithRequestTimeout(timeout time.Duration) HyprctlOption {
+	return func(c *Hyprctl) {
+		c.timeout = timeout
 	}
-	defer conn.Close()
+}
 
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, conn)
-	if err != nil {
-		return fmt.Errorf("read response from hyprctl socket: %w", err)
+func NewHyprctl(locator *Locator, opts ...HyprctlOption) (*Hyprctl, error) {
+	c := &Hyprctl{locator: locator, timeout: defaultRequestTimeout}
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	if buf.String() != "ok" {
-		return fmt.Errorf("hyprctl: %s", buf.String())
+	return c, nil
+}
+
+func (c *Hyprctl) SwitchToLayout(keyboard string, idx int) error {
+	return c.Command(context.Background(), switchLayoutRequest(keyboard, idx))
+}
+
+// SwitchToLayouts switches several keyboards in one request. The errors are in the order of the switches,
+// nil for the ones that succeeded, and the returned error is only set if the whole request failed.
+func (c *Hyprctl) SwitchToLayouts(switches []hyprboard.LayoutSwitch) ([]error, error) {
+	requests := make([]string, 0, len(switches))
+	for _, s := range switches {
+		requests = append(requests, switchLayoutRequest(s.Keyboard, s.Index))
 	}
 
-	return nil
+	return c.Batch(context.Background(), requests...)
+}
+
+func switchLayoutRequest(keyboard string, idx int) string {
+	return fmt.Sprintf("switchxkblayout %s %d", keyboard, idx)
 }
 
 func (c *Hyprctl) GetKeyboards() ([]hyprboard.Keyboard, error) {
-	conn, err := c.makeRequest("devices", "j")
+	resp, err := c.Request(context.Background(), "devices", "j")
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-
-	dec := json.NewDecoder(conn)
 
 	var devs devices
-	if err := dec.Decode(&devs); err != nil {
+	if err := json.Unmarshal(resp, &devs); err != nil {
 		return nil, fmt.Errorf("unmarshal devices: %w", err)
 	}
 
@@ -58,35 +90,181 @@ func (c *Hyprctl) GetKeyboards() ([]hyprboard.Keyboard, error) {
 	return out, nil
 }
 
-func (c *Hyprctl) makeRequest(request string, args string) (net.Conn, error) {
-	conn, err := connect(Hyperctl)
-	_, err = conn.Write([]byte(fmt.Sprintf("%s/%s", args, request)))
+// Command sends a command that's answered with "ok" when it succeeds, like dispatch or switchxkblayout.
+func (c *Hyprctl) Command(ctx context.Context, request string) error {
+	resp, err := c.Request(ctx, request, "")
 	if err != nil {
-		return nil, fmt.Errorf("write to hyprctl socket: %w", err)
+		return err
 	}
 
-	return conn, nil
+	return checkOk(request, string(resp))
 }
 
-const readBufferSize = 8192
+// Batch sends several commands in one request. See SwitchToLayouts for the errors returned.
+func (c *Hyprctl) Batch(ctx context.Context, requests ...string) ([]error, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	for _, request := range requests {
+		if strings.Contains(request, ";") {
+			return nil, fmt.Errorf("batched request %q has a ';' in it", request)
+		}
+	}
 
-func readResponse(reader io.Reader) ([]byte, error) {
-	buf := make([]byte, readBufferSize)
-	n, err := reader.Read(buf)
+	// batches don't take flags
+	resp, err := c.do(ctx, "[[BATCH]]"+strings.Join(requests, ";"))
 	if err != nil {
-		return nil, fmt.Errorf("read from hyprctl socket: %w", err)
+		return nil, err
+	}
+
+	replies := splitReplies(string(resp), len(requests))
+
+	errs := make([]error, len(requests))
+	for i, reply := range replies {
+		errs[i] = checkOk(requests[i], reply)
+	}
+
+	return errs, nil
+}
+
+// splitReplies splits the concatenated replies to a batch, possibly with whitespace in between. Replies other
+// than "ok" and "unknown request" aren't delimited, so one is taken to run until the next of those, except at the
+// end, where trailing "ok"s are split off first. An error containing "ok" can still be split wrong if it isn't last.
+func splitReplies(resp string, n int) []string {
+	replies := make([]string, n)
+
+	last := n - 1
+	for ; last > 0; last-- {
+		resp = strings.TrimRight(resp, " \n")
+		if !strings.HasSuffix(resp, "ok") || strings.TrimSpace(resp) == "ok" {
+			break
+		}
+		replies[last] = "ok"
+		resp = resp[:len(resp)-len("ok")]
 	}
 
-	for n == readBufferSize {
-		tmpBuf := make([]byte, readBufferSize)
-		tmpN, err := reader.Read(tmpBuf)
-		if err != nil {
-			return nil, fmt.Errorf("chunked read from hyprctl socket: %w", err)
+	for i := 0; i <= last; i++ {
+		resp = strings.TrimLeft(resp, " \n")
+		if i == last {
+			replies[i] = strings.TrimSpace(resp)
+			break
 		}
 
-		buf = append(buf, tmpBuf[:tmpN]...)
-		n += tmpN
+		end := nextReply(resp)
+		replies[i] = strings.TrimSpace(resp[:end])
+		resp = resp[end:]
+	}
+
+	return replies
+}
+
+var knownReplies = []string{"ok", ErrUnknownRequest.Error()}
+
+// nextReply returns where the reply at the start of resp ends.
+func nextReply(resp string) int {
+	for _, known := range knownReplies {
+		if strings.HasPrefix(resp, known) {
+			return len(known)
+		}
+	}
+
+	end := len(resp)
+	for _, known := range knownReplies {
+		if i := strings.Index(resp, known); i >= 0 {
+			end = min(end, i)
+		}
+	}
+	return end
+}
+
+func checkOk(request, resp string) error {
+	switch {
+	case strings.TrimSpace(resp) == "ok":
+		return nil
+	case isUnknownRequest(resp):
+		return fmt.Errorf("hyprctl %q: %w", request, ErrUnknownRequest)
+	}
+
+	return &ResponseError{Request: request, Response: resp}
+}
+
+func isUnknownRequest(resp string) bool {
+	return strings.TrimSpace(resp) == ErrUnknownRequest.Error()
+}
+
+// Request sends a request with flags, like "j" for JSON, and returns the raw response.
+// If ctx doesn't have a deadline, the request times out after the client's timeout.
+func (c *Hyprctl) Request(ctx context.Context, request string, flags string) ([]byte, error) {
+	resp, err := c.do(ctx, flags+"/"+request)
+	if err != nil {
+		return nil, err
+	}
+
+	if isUnknownRequest(string(resp)) {
+		return nil, fmt.Errorf("hyprctl %q: %w", request, ErrUnknownRequest)
+	}
+
+	return resp, nil
+}
+
+func (c *Hyprctl) do(ctx context.Context, request string) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	conn, err := c.locator.connect(Hyperctl)
+	if err != nil {
+		if !errors.Is(err, ErrNotRunning) {
+			err = fmt.Errorf("%w: %w", ErrNotRunning, err)
+		}
+		return nil, fmt.Errorf("connect to hyprctl socket: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("set deadline: %w", err)
+	}
+
+	// cancelling has to interrupt the connection, the deadline takes care of timing out
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, requestError("write to hyprctl socket", ctx, err)
+	}
+
+	resp, err := readResponse(conn)
+	if err != nil {
+		return nil, requestError("read from hyprctl socket", ctx, err)
+	}
+
+	return resp, nil
+}
+
+// requestError reports the context's error instead of a timeout it caused.
+func requestError(msg string, ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%s: %w", msg, ctxErr)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+const readBufferSize = 8192
+
+// readResponse reads until Hyprland closes the connection, which it does after replying.
+func readResponse(conn net.Conn) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(readBufferSize)
+
+	if _, err := io.Copy(&buf, conn); err != nil {
+		return nil, err
 	}
 
-	return buf[:n], nil
+	return buf.Bytes(), nil
 }
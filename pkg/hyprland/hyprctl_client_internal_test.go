@@ -0,0 +1,62 @@
+package hyprland
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveReply makes an instance whose hyprctl socket gives the same reply to every request.
+func serveReply(t *testing.T, reply string) *Locator {
+	t.Helper()
+
+	// unix socket paths are short, t.TempDir may be too long
+	baseDir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(baseDir) })
+
+	listenInstance(t, baseDir, "test")
+	listener, err := net.Listen("unix", filepath.Join(baseDir, "test", ".socket.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Read(make([]byte, 1024))
+			_, _ = io.WriteString(conn, reply)
+			_ = conn.Close()
+		}
+	}()
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "test")
+	return NewLocator(baseDir)
+}
+
+func TestUnknownRequestWithWhitespace(t *testing.T) {
+	hyprctl, err := NewHyprctl(serveReply(t, "unknown request\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hyprctl.Request(context.Background(), "frobnicate", "j"); !errors.Is(err, ErrUnknownRequest) {
+		t.Errorf("request: got %v, want %v", err, ErrUnknownRequest)
+	}
+	if _, err := hyprctl.GetKeyboards(); !errors.Is(err, ErrUnknownRequest) {
+		t.Errorf("get keyboards: got %v, want %v", err, ErrUnknownRequest)
+	}
+	if err := hyprctl.Command(context.Background(), "frobnicate"); !errors.Is(err, ErrUnknownRequest) {
+		t.Errorf("command: got %v, want %v", err, ErrUnknownRequest)
+	}
+}
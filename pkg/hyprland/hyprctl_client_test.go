@@ -0,0 +1,152 @@
+package hyprland_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/hyprland"
+	"codeberg.org/miketth/hyprboard/pkg/hyprland/hyprlandtest"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+var (
+	us = hyprlandtest.Layout{Code: "us", Name: "English (US)"}
+	hu = hyprlandtest.Layout{Code: "hu", Name: "Hungarian"}
+)
+
+func startHyprctl(t *testing.T) (*hyprlandtest.Server, *hyprland.Hyprctl) {
+	t.Helper()
+
+	server := hyprlandtest.NewServer(t,
+		hyprlandtest.Keyboard{Name: "kbd", Layouts: []hyprlandtest.Layout{us, hu}, Main: true},
+		hyprlandtest.Keyboard{Name: "other", Layouts: []hyprlandtest.Layout{us, hu}},
+	)
+
+	hyprctl, err := hyprland.NewHyprctl(server.Locator())
+	if err != nil {
+		t.Fatalf("create hyprctl: %v", err)
+	}
+
+	return server, hyprctl
+}
+
+func TestBatchMapsRepliesToRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []string
+		// failed is the indexes of the requests that should fail
+		failed []int
+	}{
+		{
+			name:     "all ok",
+			requests: []string{"switchxkblayout kbd 1", "switchxkblayout other 1"},
+		},
+		{
+			name:     "error in the middle",
+			requests: []string{"switchxkblayout kbd 1", "switchxkblayout missing 0", "switchxkblayout other 1"},
+			failed:   []int{1},
+		},
+		{
+			name:     "error first",
+			requests: []string{"switchxkblayout missing 0", "switchxkblayout kbd 1", "switchxkblayout other 1"},
+			failed:   []int{0},
+		},
+		{
+			name:     "error last",
+			requests: []string{"switchxkblayout kbd 1", "switchxkblayout other 1", "switchxkblayout kbd 5"},
+			failed:   []int{2},
+		},
+		{
+			name: "errors around an ok",
+			requests: []string{
+				"switchxkblayout missing 0", "switchxkblayout kbd 1", "switchxkblayout kbd 5", "switchxkblayout other 1",
+			},
+			failed: []int{0, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, hyprctl := startHyprctl(t)
+
+			errs, err := hyprctl.Batch(context.Background(), tt.requests...)
+			if err != nil {
+				t.Fatalf("batch: %v", err)
+			}
+			if len(errs) != len(tt.requests) {
+				t.Fatalf("got %d errors for %d requests", len(errs), len(tt.requests))
+			}
+
+			for i, err := range errs {
+				shouldFail := slices.Contains(tt.failed, i)
+
+				var respErr *hyprland.ResponseError
+				switch {
+				case shouldFail && !errors.As(err, &respErr):
+					t.Errorf("request %q: got %v, want a response error", tt.requests[i], err)
+				case shouldFail && respErr.Request != tt.requests[i]:
+					t.Errorf("request %q: got the error for %q", tt.requests[i], respErr.Request)
+				case !shouldFail && err != nil:
+					t.Errorf("request %q: %v", tt.requests[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestSwitchToLayouts(t *testing.T) {
+	server, hyprctl := startHyprctl(t)
+
+	errs, err := hyprctl.SwitchToLayouts([]hyprboard.LayoutSwitch{
+		{Keyboard: "missing", Index: 0},
+		{Keyboard: "kbd", Index: 1},
+	})
+	if err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+
+	if errs[0] == nil {
+		t.Errorf("switching a missing keyboard succeeded")
+	}
+	if errs[1] != nil {
+		t.Errorf("switch kbd: %v", errs[1])
+	}
+	if got := server.ActiveLayout("kbd"); got != 1 {
+		t.Errorf("kbd is on layout %d, want 1", got)
+	}
+}
+
+func TestUnknownRequest(t *testing.T) {
+	_, hyprctl := startHyprctl(t)
+
+	if err := hyprctl.Command(context.Background(), "frobnicate"); !errors.Is(err, hyprland.ErrUnknownRequest) {
+		t.Errorf("command: got %v, want %v", err, hyprland.ErrUnknownRequest)
+	}
+
+	errs, err := hyprctl.Batch(context.Background(), "switchxkblayout kbd 1", "frobnicate", "switchxkblayout other 1")
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if !errors.Is(errs[1], hyprland.ErrUnknownRequest) {
+		t.Errorf("unknown request in batch: got %v, want %v", errs[1], hyprland.ErrUnknownRequest)
+	}
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Errorf("request %d: %v", i, errs[i])
+		}
+	}
+}
+
+func TestNotRunning(t *testing.T) {
+	server, hyprctl := startHyprctl(t)
+	server.Close()
+
+	if err := hyprctl.Command(context.Background(), "switchxkblayout kbd 1"); !errors.Is(err, hyprland.ErrNotRunning) {
+		t.Errorf("command: got %v, want %v", err, hyprland.ErrNotRunning)
+	}
+
+	if _, err := hyprctl.Batch(context.Background(), "switchxkblayout kbd 1"); !errors.Is(err, hyprland.ErrNotRunning) {
+		t.Errorf("batch: got %v, want %v", err, hyprland.ErrNotRunning)
+	}
+}
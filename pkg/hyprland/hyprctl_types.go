@@ -6,11 +6,19 @@ import (
 )
 
 type keyboard struct {
+	Address      string `json:"address"`
 	Name         string `json:"name"`
+	Rules        string `json:"rules"`
+	Model        string `json:"model"`
 	Layout       string `json:"layout"`
 	Variant      string `json:"variant"`
 	Options      string `json:"options"`
 	ActiveKeymap string `json:"active_keymap"`
+	// ActiveLayoutIndex is only reported by newer Hyprland versions
+	ActiveLayoutIndex *int `json:"active_layout_index"`
+	CapsLock          bool `json:"capsLock"`
+	NumLock           bool `json:"numLock"`
+	Main              bool `json:"main"`
 }
 
 type devices struct {
@@ -18,9 +26,27 @@ type devices struct {
 }
 
 func (k keyboard) ToKeyboard() hyprboard.Keyboard {
+	activeIdx := -1
+	if k.ActiveLayoutIndex != nil {
+		activeIdx = *k.ActiveLayoutIndex
+	}
+
 	return hyprboard.Keyboard{
-		Name:     k.Name,
-		Layouts:  strings.Split(k.Layout, ","),
-		Variants: strings.Split(k.Variant, ","),
+		Name:              k.Name,
+		Layouts:           strings.Split(k.Layout, ","),
+		Variants:          strings.Split(k.Variant, ","),
+		Rules:             k.Rules,
+		Model:             k.Model,
+		Options:           splitOptions(k.Options),
+		ActiveKeymap:      k.ActiveKeymap,
+		ActiveLayoutIndex: activeIdx,
+		Main:              k.Main,
+	}
+}
+
+func splitOptions(options string) []string {
+	if options == "" {
+		return nil
 	}
+	return strings.Split(options, ",")
 }
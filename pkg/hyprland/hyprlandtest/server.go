@@ -0,0 +1,469 @@
+// Package hyprlandtest provides a fake Hyprland for tests, serving the event and hyprctl sockets.
+package hyprlandtest
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprland"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Signature is the HYPRLAND_INSTANCE_SIGNATURE of the fake instance.
+const Signature = "hyprlandtest"
+
+type Layout struct {
+	Code    string
+	Variant string
+	// Name is what Hyprland calls the layout in activelayout events, like "English (US)".
+	Name string
+}
+
+type Keyboard struct {
+	Name    string
+	Layouts []Layout
+	Active  int
+	Main    bool
+}
+
+type Window struct {
+	Class   string
+	Title   string
+	Address string
+}
+
+// Server is a fake Hyprland instance. Layout switches requested through hyprctl change its keyboards
+// and are echoed on the event socket, like Hyprland does.
+type Server struct {
+	// Dir is the directory with the instance directory in it, to use with hyprland.NewLocator.
+	Dir string
+
+	t      testing.TB
+	events net.Listener
+	ctl    net.Listener
+	// handlers tracks the hyprctl goroutines, so none of them reports to t after the test ended
+	handlers sync.WaitGroup
+
+	lock       sync.Mutex
+	keyboards  []Keyboard
+	window     Window
+	clients    []net.Conn
+	hasClients chan struct{}
+	requests   []string
+	changed    chan struct{}
+}
+
+// NewServer starts a fake Hyprland with the keyboards, and points HYPRLAND_INSTANCE_SIGNATURE at it.
+// It's stopped when the test ends.
+func NewServer(t testing.TB, keyboards ...Keyboard) *Server {
+	t.Helper()
+
+	// unix socket paths are short, t.TempDir may be too long
+	dir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	instanceDir := filepath.Join(dir, Signature)
+	if err := os.Mkdir(instanceDir, 0o700); err != nil {
+		t.Fatalf("create instance dir: %v", err)
+	}
+
+	s := &Server{
+		Dir:        dir,
+		t:          t,
+		keyboards:  keyboards,
+		hasClients: make(chan struct{}),
+		changed:    make(chan struct{}),
+	}
+
+	s.events, err = net.Listen("unix", filepath.Join(instanceDir, ".socket2.sock"))
+	if err != nil {
+		t.Fatalf("listen on event socket: %v", err)
+	}
+	s.ctl, err = net.Listen("unix", filepath.Join(instanceDir, ".socket.sock"))
+	if err != nil {
+		t.Fatalf("listen on hyprctl socket: %v", err)
+	}
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", Signature)
+	t.Cleanup(s.Close)
+
+	go s.acceptEventClients()
+	s.handlers.Add(1)
+	go s.serveHyprctl()
+
+	return s
+}
+
+// Locator returns a locator that finds the server.
+func (s *Server) Locator() *hyprland.Locator {
+	return hyprland.NewLocator(s.Dir)
+}
+
+func (s *Server) Close() {
+	_ = s.events.Close()
+	_ = s.ctl.Close()
+	s.DropClients()
+	s.handlers.Wait()
+}
+
+// DropClients closes the event connections, like a Hyprland restart would.
+func (s *Server) DropClients() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, c := range s.clients {
+		_ = c.Close()
+	}
+	s.clients = nil
+	s.hasClients = make(chan struct{})
+}
+
+func (s *Server) acceptEventClients() {
+	for {
+		conn, err := s.events.Accept()
+		if err != nil {
+			return
+		}
+
+		s.lock.Lock()
+		s.clients = append(s.clients, conn)
+		if len(s.clients) == 1 {
+			close(s.hasClients)
+		}
+		s.lock.Unlock()
+	}
+}
+
+// Emit sends an event line, like "activewindow>>kitty,~", to every event client. It waits for a client
+// to connect if there's none yet.
+func (s *Server) Emit(line string) {
+	s.lock.Lock()
+	hasClients := s.hasClients
+	s.lock.Unlock()
+
+	select {
+	case <-hasClients:
+	case <-time.After(5 * time.Second):
+		s.t.Errorf("no event client connected to send %q to", line)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.emitLocked(line)
+}
+
+func (s *Server) emitLocked(line string) {
+	for _, c := range s.clients {
+		// a client that went away is the client's problem
+		_, _ = io.WriteString(c, line+"\n")
+	}
+}
+
+// Focus focuses a window, sending the events Hyprland sends for it.
+func (s *Server) Focus(window Window) {
+	s.lock.Lock()
+	s.window = window
+	s.lock.Unlock()
+
+	s.Emit(fmt.Sprintf("activewindow>>%s,%s", window.Class, window.Title))
+	s.Emit(fmt.Sprintf("activewindowv2>>%s", strings.TrimPrefix(window.Address, "0x")))
+}
+
+// SwitchLayout switches a keyboard to a layout like the user would, by its index.
+func (s *Server) SwitchLayout(keyboard string, idx int) {
+	s.lock.Lock()
+	line, err := s.switchLocked(keyboard, idx)
+	s.lock.Unlock()
+
+	if err != nil {
+		s.t.Errorf("switch layout: %v", err)
+		return
+	}
+	s.Emit(line)
+}
+
+func (s *Server) switchLocked(keyboard string, idx int) (string, error) {
+	for i := range s.keyboards {
+		k := &s.keyboards[i]
+		if k.Name != keyboard {
+			continue
+		}
+
+		if idx < 0 || idx >= len(k.Layouts) {
+			return "", fmt.Errorf("invalid layout index %d for %s", idx, keyboard)
+		}
+
+		k.Active = idx
+		s.notifyLocked()
+		return fmt.Sprintf("activelayout>>%s,%s", k.Name, k.Layouts[idx].Name), nil
+	}
+
+	return "", fmt.Errorf("no keyboard %q", keyboard)
+}
+
+// ActiveLayout returns the index of the active layout of a keyboard.
+func (s *Server) ActiveLayout(keyboard string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, k := range s.keyboards {
+		if k.Name == keyboard {
+			return k.Active
+		}
+	}
+	return -1
+}
+
+// WaitForLayout waits until a keyboard is on the layout at the index, and reports whether it got there in time.
+func (s *Server) WaitForLayout(keyboard string, idx int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		s.lock.Lock()
+		changed := s.changed
+		s.lock.Unlock()
+
+		if s.ActiveLayout(keyboard) == idx {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Requests returns the hyprctl requests received so far, with their flags.
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serveHyprctl() {
+	defer s.handlers.Done()
+
+	for {
+		conn, err := s.ctl.Accept()
+		if err != nil {
+			return
+		}
+
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			s.handleHyprctl(conn)
+		}()
+	}
+}
+
+func (s *Server) handleHyprctl(conn net.Conn) {
+	defer conn.Close()
+
+	// Close waits for this, so a client that never sends anything can't hang it
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// hyprctl doesn't delimit requests, but it sends them in one write
+	buf := make([]byte, 8192)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+	request := string(buf[:n])
+
+	s.lock.Lock()
+	s.requests = append(s.requests, request)
+	s.notifyLocked()
+	s.lock.Unlock()
+
+	var reply string
+	if batch, ok := strings.CutPrefix(request, "[[BATCH]]"); ok {
+		for _, r := range strings.Split(batch, ";") {
+			reply += s.reply(r)
+		}
+	} else {
+		reply = s.reply(request)
+	}
+
+	_, _ = io.WriteString(conn, reply)
+}
+
+func (s *Server) reply(request string) string {
+	flags, command, ok := strings.Cut(request, "/")
+	if !ok {
+		command = flags
+		flags = ""
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "unknown request"
+	}
+
+	switch args[0] {
+	case "devices":
+		return s.devices()
+	case "activewindow":
+		return s.activeWindow(strings.Contains(flags, "j"))
+	case "switchxkblayout":
+		return s.switchxkblayout(args[1:])
+	}
+
+	return "unknown request"
+}
+
+func (s *Server) devices() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	type keyboard struct {
+		Name              string `json:"name"`
+		Rules             string `json:"rules"`
+		Model             string `json:"model"`
+		Layout            string `json:"layout"`
+		Variant           string `json:"variant"`
+		Options           string `json:"options"`
+		ActiveKeymap      string `json:"active_keymap"`
+		ActiveLayoutIndex int    `json:"active_layout_index"`
+		Main              bool   `json:"main"`
+	}
+
+	keyboards := make([]keyboard, 0, len(s.keyboards))
+	for _, k := range s.keyboards {
+		var codes, variants []string
+		for _, l := range k.Layouts {
+			codes = append(codes, l.Code)
+			variants = append(variants, l.Variant)
+		}
+
+		keyboards = append(keyboards, keyboard{
+			Name:              k.Name,
+			Layout:            strings.Join(codes, ","),
+			Variant:           strings.Join(variants, ","),
+			ActiveKeymap:      k.Layouts[k.Active].Name,
+			ActiveLayoutIndex: k.Active,
+			Main:              k.Main,
+		})
+	}
+
+	data, err := json.Marshal(map[string]any{"keyboards": keyboards})
+	if err != nil {
+		s.t.Errorf("marshal devices: %v", err)
+	}
+	return string(data)
+}
+
+func (s *Server) activeWindow(asJSON bool) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !asJSON {
+		return fmt.Sprintf("Window %s -> %s:\n\tclass: %s\n", s.window.Address, s.window.Title, s.window.Class)
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"address": s.window.Address,
+		"class":   s.window.Class,
+		"title":   s.window.Title,
+	})
+	if err != nil {
+		s.t.Errorf("marshal active window: %v", err)
+	}
+	return string(data)
+}
+
+func (s *Server) switchxkblayout(args []string) string {
+	if len(args) != 2 {
+		return "invalid args"
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.layoutIndex(args[0], args[1])
+	if err != nil {
+		return err.Error()
+	}
+
+	line, err := s.switchLocked(args[0], idx)
+	if err != nil {
+		return err.Error()
+	}
+
+	// Hyprland tells everyone about the switch, the one who asked for it too
+	s.emitLocked(line)
+	return "ok"
+}
+
+func (s *Server) layoutIndex(keyboard, arg string) (int, error) {
+	for _, k := range s.keyboards {
+		if k.Name != keyboard {
+			continue
+		}
+
+		switch arg {
+		case "next":
+			return (k.Active + 1) % len(k.Layouts), nil
+		case "prev":
+			return (k.Active + len(k.Layouts) - 1) % len(k.Layouts), nil
+		}
+
+		idx, err := strconv.Atoi(arg)
+		if err != nil {
+			return 0, fmt.Errorf("invalid layout index %q", arg)
+		}
+		return idx, nil
+	}
+
+	return 0, fmt.Errorf("device not found")
+}
+
+// Step is a step of a scripted scenario.
+type Step func(s *Server)
+
+// Play runs a scenario, one step after the other.
+func (s *Server) Play(steps ...Step) {
+	for _, step := range steps {
+		step(s)
+	}
+}
+
+func FocusStep(window Window) Step {
+	return func(s *Server) { s.Focus(window) }
+}
+
+func SwitchLayoutStep(keyboard string, idx int) Step {
+	return func(s *Server) { s.SwitchLayout(keyboard, idx) }
+}
+
+func EmitStep(line string) Step {
+	return func(s *Server) { s.Emit(line) }
+}
+
+// WaitStep waits for a keyboard to get to a layout, failing the test if it doesn't.
+func WaitStep(keyboard string, idx int) Step {
+	return func(s *Server) {
+		s.t.Helper()
+		if !s.WaitForLayout(keyboard, idx, 2*time.Second) {
+			s.t.Errorf("keyboard %q is on layout %d, expected %d", keyboard, s.ActiveLayout(keyboard), idx)
+		}
+	}
+}
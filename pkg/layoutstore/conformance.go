@@ -0,0 +1,279 @@
+// Package layoutstore has checks that every hyprboard.ActiveLayoutStore implementation has to pass.
+package layoutstore
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"testing"
+)
+
+// Implementation describes a store to check.
+type Implementation struct {
+	// Open opens a store that keeps its data in dir, which is empty the first time it's opened.
+	// Stores that are io.Closers are closed by the checks.
+	Open func(t *testing.T, dir string) hyprboard.ActiveLayoutStore
+	// Persistent is set if a store opened again with the same dir has the data of the previous one.
+	Persistent bool
+}
+
+var (
+	us     = hyprboard.Layout{Code: "us"}
+	hu     = hyprboard.Layout{Code: "hu"}
+	dvorak = hyprboard.Layout{Code: "us", Variant: "dvorak"}
+)
+
+// RunConformance checks that a store behaves like the others:
+//   - GetActiveLayout returns nil for keys without layouts
+//   - ListActiveLayouts returns an empty map for scopes without layouts
+//   - returned maps can be modified without changing what's stored
+//   - scopes, keys and keyboards are independent
+//   - it can be used from many goroutines
+//   - Persistent stores keep their data when opened again
+func RunConformance(t *testing.T, impl Implementation) {
+	t.Run("Empty", func(t *testing.T) { testEmpty(t, impl) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, impl) })
+	t.Run("MultipleKeyboards", func(t *testing.T) { testMultipleKeyboards(t, impl) })
+	t.Run("Scopes", func(t *testing.T) { testScopes(t, impl) })
+	t.Run("Forget", func(t *testing.T) { testForget(t, impl) })
+	t.Run("Copies", func(t *testing.T) { testCopies(t, impl) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, impl) })
+	t.Run("Reopen", func(t *testing.T) {
+		if !impl.Persistent {
+			t.Skip("store isn't persistent")
+		}
+		testReopen(t, impl)
+	})
+}
+
+func open(t *testing.T, impl Implementation, dir string) hyprboard.ActiveLayoutStore {
+	t.Helper()
+
+	store := impl.Open(t, dir)
+	t.Cleanup(func() { closeStore(t, store) })
+
+	return store
+}
+
+func closeStore(t *testing.T, store hyprboard.ActiveLayoutStore) {
+	t.Helper()
+
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			t.Errorf("close: %v", err)
+		}
+	}
+}
+
+func set(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope, key, keyboard string, layout hyprboard.Layout) {
+	t.Helper()
+
+	if err := store.SetActiveLayout(scope, key, keyboard, layout); err != nil {
+		t.Fatalf("set %s %q %q: %v", scope, key, keyboard, err)
+	}
+}
+
+func forget(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope, key string) {
+	t.Helper()
+
+	if err := store.ForgetActiveLayout(scope, key); err != nil {
+		t.Fatalf("forget %s %q: %v", scope, key, err)
+	}
+}
+
+func get(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope, key string) map[string]hyprboard.Layout {
+	t.Helper()
+
+	layouts, err := store.GetActiveLayout(scope, key)
+	if err != nil {
+		t.Fatalf("get %s %q: %v", scope, key, err)
+	}
+
+	return layouts
+}
+
+func list(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope) map[string]map[string]hyprboard.Layout {
+	t.Helper()
+
+	layouts, err := store.ListActiveLayouts(scope)
+	if err != nil {
+		t.Fatalf("list %s: %v", scope, err)
+	}
+
+	return layouts
+}
+
+func expectLayouts(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope, key string, want map[string]hyprboard.Layout) {
+	t.Helper()
+
+	got := get(t, store, scope, key)
+	if want == nil && got != nil {
+		t.Errorf("get %s %q: expected nil, got %v", scope, key, got)
+		return
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("get %s %q: expected %v, got %v", scope, key, want, got)
+	}
+}
+
+func expectList(t *testing.T, store hyprboard.ActiveLayoutStore, scope hyprboard.Scope, want map[string]map[string]hyprboard.Layout) {
+	t.Helper()
+
+	got := list(t, store, scope)
+	if got == nil {
+		t.Errorf("list %s: expected a map, got nil", scope)
+		return
+	}
+	if !maps.EqualFunc(got, want, maps.Equal[map[string]hyprboard.Layout]) {
+		t.Errorf("list %s: expected %v, got %v", scope, want, got)
+	}
+}
+
+func testEmpty(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", nil)
+	expectList(t, store, hyprboard.ScopeClass, map[string]map[string]hyprboard.Layout{})
+
+	// forgetting something that isn't there is fine
+	forget(t, store, hyprboard.ScopeClass, "kitty")
+}
+
+func testOverwrite(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", us)
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", hu)
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": hu})
+
+	// the variant is part of the layout
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", dvorak)
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": dvorak})
+}
+
+func testMultipleKeyboards(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", us)
+	set(t, store, hyprboard.ScopeClass, "kitty", "ext", hu)
+	set(t, store, hyprboard.ScopeClass, "firefox", "kbd", hu)
+
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": us, "ext": hu})
+	expectLayouts(t, store, hyprboard.ScopeClass, "firefox", map[string]hyprboard.Layout{"kbd": hu})
+	expectList(t, store, hyprboard.ScopeClass, map[string]map[string]hyprboard.Layout{
+		"kitty":   {"kbd": us, "ext": hu},
+		"firefox": {"kbd": hu},
+	})
+}
+
+func testScopes(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	set(t, store, hyprboard.ScopeClass, "1", "kbd", us)
+	set(t, store, hyprboard.ScopeWorkspace, "1", "kbd", hu)
+
+	expectLayouts(t, store, hyprboard.ScopeClass, "1", map[string]hyprboard.Layout{"kbd": us})
+	expectLayouts(t, store, hyprboard.ScopeWorkspace, "1", map[string]hyprboard.Layout{"kbd": hu})
+	expectLayouts(t, store, hyprboard.ScopeAddress, "1", nil)
+	expectList(t, store, hyprboard.ScopeAddress, map[string]map[string]hyprboard.Layout{})
+
+	forget(t, store, hyprboard.ScopeWorkspace, "1")
+	expectLayouts(t, store, hyprboard.ScopeClass, "1", map[string]hyprboard.Layout{"kbd": us})
+}
+
+func testForget(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", us)
+	set(t, store, hyprboard.ScopeClass, "kitty", "ext", hu)
+	set(t, store, hyprboard.ScopeClass, "firefox", "kbd", hu)
+
+	forget(t, store, hyprboard.ScopeClass, "kitty")
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", nil)
+	expectList(t, store, hyprboard.ScopeClass, map[string]map[string]hyprboard.Layout{
+		"firefox": {"kbd": hu},
+	})
+
+	// a forgotten key can be used again, without the keyboards it had before
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", dvorak)
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": dvorak})
+}
+
+func testCopies(t *testing.T, impl Implementation) {
+	store := open(t, impl, t.TempDir())
+
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", us)
+
+	get(t, store, hyprboard.ScopeClass, "kitty")["kbd"] = hu
+	listed := list(t, store, hyprboard.ScopeClass)
+	listed["kitty"]["kbd"] = hu
+	listed["firefox"] = map[string]hyprboard.Layout{"kbd": hu}
+
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": us})
+	expectList(t, store, hyprboard.ScopeClass, map[string]map[string]hyprboard.Layout{
+		"kitty": {"kbd": us},
+	})
+}
+
+func testConcurrent(t *testing.T, impl Implementation) {
+	const (
+		writers   = 8
+		keyboards = 16
+	)
+
+	store := open(t, impl, t.TempDir())
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			for k := 0; k < keyboards; k++ {
+				keyboard := fmt.Sprintf("kbd%d", k)
+				if err := store.SetActiveLayout(hyprboard.ScopeClass, key, keyboard, us); err != nil {
+					t.Errorf("set %q %q: %v", key, keyboard, err)
+					return
+				}
+				if _, err := store.GetActiveLayout(hyprboard.ScopeClass, key); err != nil {
+					t.Errorf("get %q: %v", key, err)
+					return
+				}
+				if _, err := store.ListActiveLayouts(hyprboard.ScopeClass); err != nil {
+					t.Errorf("list: %v", err)
+					return
+				}
+			}
+		}(fmt.Sprintf("app%d", w))
+	}
+	wg.Wait()
+
+	listed := list(t, store, hyprboard.ScopeClass)
+	if len(listed) != writers {
+		t.Fatalf("expected %d keys, got %d", writers, len(listed))
+	}
+	for key, layouts := range listed {
+		if len(layouts) != keyboards {
+			t.Errorf("expected %d keyboards for %q, got %d", keyboards, key, len(layouts))
+		}
+	}
+}
+
+func testReopen(t *testing.T, impl Implementation) {
+	dir := t.TempDir()
+
+	store := impl.Open(t, dir)
+	set(t, store, hyprboard.ScopeClass, "kitty", "kbd", us)
+	set(t, store, hyprboard.ScopeClass, "kitty", "ext", dvorak)
+	set(t, store, hyprboard.ScopeWorkspace, "1", "kbd", hu)
+	set(t, store, hyprboard.ScopeClass, "firefox", "kbd", hu)
+	forget(t, store, hyprboard.ScopeClass, "firefox")
+	closeStore(t, store)
+
+	store = open(t, impl, dir)
+	expectLayouts(t, store, hyprboard.ScopeClass, "kitty", map[string]hyprboard.Layout{"kbd": us, "ext": dvorak})
+	expectLayouts(t, store, hyprboard.ScopeWorkspace, "1", map[string]hyprboard.Layout{"kbd": hu})
+	expectLayouts(t, store, hyprboard.ScopeClass, "firefox", nil)
+}
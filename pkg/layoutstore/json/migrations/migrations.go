@@ -0,0 +1,120 @@
+// Package migrations upgrades JSON state files written by older versions to the current format.
+package migrations
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Version is the version of the current format.
+const Version = 2
+
+var (
+	ErrTooNew         = errors.New("state file is newer than supported")
+	ErrInvalidVersion = errors.New("invalid state file version")
+)
+
+// Migration upgrades a state file from the previous version to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(data []byte) ([]byte, error)
+}
+
+// Migrations upgrade from version 0, the first one, to the current one, in order.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "keep layouts per scope, the existing ones are per class",
+		Up:          addScopes,
+	},
+	{
+		Version:     2,
+		Description: "wrap the layouts in an envelope with the version and metadata",
+		Up:          addEnvelope,
+	},
+}
+
+// Migrate upgrades a state file to the current version, and returns the version it had.
+func Migrate(data []byte) ([]byte, int, error) {
+	version, err := DetectVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if version < 0 {
+		return nil, version, fmt.Errorf("%w: %d", ErrInvalidVersion, version)
+	}
+	if version > Version {
+		return nil, version, fmt.Errorf("%w (version %d, up to %d is supported)", ErrTooNew, version, Version)
+	}
+
+	migrated := data
+	for _, m := range Migrations[version:] {
+		migrated, err = m.Up(migrated)
+		if err != nil {
+			return nil, version, fmt.Errorf("migrate to version %d (%s): %w", m.Version, m.Description, err)
+		}
+	}
+
+	return migrated, version, nil
+}
+
+type layout struct {
+	Code    string
+	Variant string
+}
+
+// DetectVersion tells the version of a state file. Only files since version 2 have it written in them.
+func DetectVersion(data []byte) (int, error) {
+	var envelope struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Version != nil {
+		return *envelope.Version, nil
+	}
+
+	// version 1 is layouts by scope, key and keyboard. Version 0 can look the same if its keyboards have
+	// nothing remembered, but its keys are classes, not scopes.
+	var scoped map[hyprboard.Scope]map[string]map[string]layout
+	err := json.Unmarshal(data, &scoped)
+	if err == nil && len(scoped) > 0 && knownScopes(scoped) {
+		return 1, nil
+	}
+
+	// version 0 is layouts by class and keyboard
+	var legacy map[string]map[string]layout
+	if legacyErr := json.Unmarshal(data, &legacy); legacyErr != nil {
+		if err == nil {
+			err = legacyErr
+		}
+		return 0, fmt.Errorf("unknown format: %w", err)
+	}
+
+	return 0, nil
+}
+
+func knownScopes(scoped map[hyprboard.Scope]map[string]map[string]layout) bool {
+	for scope := range scoped {
+		switch scope {
+		case hyprboard.ScopeClass, hyprboard.ScopeAddress, hyprboard.ScopeWorkspace, hyprboard.ScopeMonitor:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func addScopes(data []byte) ([]byte, error) {
+	return json.Marshal(map[string]json.RawMessage{"class": data})
+}
+
+func addEnvelope(data []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"version":  2,
+		"metadata": map[string]any{},
+		"layouts":  json.RawMessage(data),
+	})
+}
@@ -0,0 +1,127 @@
+package migrations_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/json/migrations"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		version int
+	}{
+		{name: "empty", data: `{}`, version: 0},
+		{name: "classes", data: `{"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}`, version: 0},
+		{name: "class without layouts", data: `{"firefox": {}}`, version: 0},
+		{name: "class named like a scope", data: `{"class": {}, "firefox": {}}`, version: 0},
+		{name: "scopes", data: `{"class": {"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}}`, version: 1},
+		{name: "scopes without layouts", data: `{"class": {}, "workspace": {}, "monitor": {}}`, version: 1},
+		{name: "envelope", data: `{"version": 2, "metadata": {}, "layouts": {}}`, version: 2},
+		{name: "negative", data: `{"version": -1, "layouts": {}}`, version: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := migrations.DetectVersion([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.version {
+				t.Errorf("got version %d, want %d", version, tt.version)
+			}
+		})
+	}
+}
+
+func TestDetectVersionOfUnknownFormat(t *testing.T) {
+	for _, data := range []string{`[]`, `{"kitty": []}`, `"class"`} {
+		if version, err := migrations.DetectVersion([]byte(data)); err == nil {
+			t.Errorf("%s: got version %d, want an error", data, version)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		version int
+		layouts string
+	}{
+		{
+			name:    "classes",
+			data:    `{"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}`,
+			version: 0,
+			layouts: `{"class": {"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}}`,
+		},
+		{
+			name:    "class without layouts",
+			data:    `{"firefox": {}}`,
+			version: 0,
+			layouts: `{"class": {"firefox": {}}}`,
+		},
+		{
+			name:    "scopes",
+			data:    `{"class": {"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}}`,
+			version: 1,
+			layouts: `{"class": {"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}}`,
+		},
+		{
+			name:    "current",
+			data:    `{"version": 2, "metadata": {}, "layouts": {"monitor": {}}}`,
+			version: 2,
+			layouts: `{"monitor": {}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, version, err := migrations.Migrate([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.version {
+				t.Errorf("got version %d, want %d", version, tt.version)
+			}
+
+			var envelope struct {
+				Version int
+				Layouts any
+			}
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			if envelope.Version != migrations.Version {
+				t.Errorf("migrated to version %d, want %d", envelope.Version, migrations.Version)
+			}
+
+			var want any
+			if err := json.Unmarshal([]byte(tt.layouts), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(envelope.Layouts, want) {
+				t.Errorf("got layouts %v, want %v", envelope.Layouts, want)
+			}
+		})
+	}
+}
+
+func TestMigrateRejectsUnknownVersions(t *testing.T) {
+	tests := []struct {
+		data string
+		err  error
+	}{
+		{data: `{"version": -1, "layouts": {}}`, err: migrations.ErrInvalidVersion},
+		{data: `{"version": 3, "layouts": {}}`, err: migrations.ErrTooNew},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := migrations.Migrate([]byte(tt.data)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.data, err, tt.err)
+		}
+	}
+}
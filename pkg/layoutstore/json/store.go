@@ -2,139 +2,366 @@ package json
 
 import (
 	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/json/migrations"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go.uber.org/zap"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
+// DefaultDebounce is how long changes are kept in memory before they're saved.
+const DefaultDebounce = 2 * time.Second
+
+var errCorrupt = errors.New("corrupt state file")
+
 type LayoutStore struct {
-	layouts map[string]map[string]hyprboard.Layout
-	file    *os.File
-	lock    sync.Mutex
-	dirty   bool
+	layouts  map[hyprboard.Scope]map[string]map[string]hyprboard.Layout
+	metadata Metadata
+	filename string
+	debounce time.Duration
+	timer    *time.Timer
+	stop     func() bool
+	lock     sync.Mutex
+	dirty    bool
+	closed   bool
+	log      *zap.SugaredLogger
 }
 
-func NewLayoutStore(ctx context.Context, filename string) (*LayoutStore, error) {
-	fileExists := true
-	_, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		fileExists = false
-	}
+type Option func(s *LayoutStore)
 
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("open file: %w", err)
+// WithDebounce sets how long after the first unsaved change the layouts are saved.
+func WithDebounce(debounce time.Duration) Option {
+	return func(s *LayoutStore) {
+		s.debounce = debounce
 	}
+}
 
+// NewLayoutStore loads the layouts from the file, and saves them to it when they change and when ctx is done.
+// If the file is corrupt, it's moved aside and the backup of the previous version is loaded instead.
+func NewLayoutStore(ctx context.Context, filename string, log *zap.SugaredLogger, opts ...Option) (*LayoutStore, error) {
 	store := &LayoutStore{
-		layouts: make(map[string]map[string]hyprboard.Layout),
-		file:    file,
-		dirty:   true,
+		layouts:  make(map[hyprboard.Scope]map[string]map[string]hyprboard.Layout),
+		filename: filename,
+		debounce: DefaultDebounce,
+		log:      log,
 	}
 
-	if fileExists {
-		err = store.load()
-		if err != nil {
-			return nil, fmt.Errorf("load: %w", err)
-		}
+	for _, opt := range opts {
+		opt(store)
+	}
 
-		store.dirty = false
+	if err := store.load(); err != nil {
+		return nil, fmt.Errorf("load: %w", err)
 	}
 
-	go store.saveLooper(ctx)
+	store.stop = context.AfterFunc(ctx, store.flush)
 
 	return store, nil
 }
 
+func (s *LayoutStore) backupFilename() string {
+	return s.filename + ".bak"
+}
+
+// Close saves unsaved changes. Changes made after it are not saved.
 func (s *LayoutStore) Close() error {
-	return s.file.Close()
+	s.stop()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if err := s.save(); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	return nil
 }
 
 func (s *LayoutStore) load() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	_, err := s.file.Seek(0, 0)
-	if err != nil {
-		return fmt.Errorf("seek to start of file: %w", err)
+
+	st, version, err := readFile(s.filename)
+	switch {
+	case err == nil:
+		s.useState(st, version)
+		return nil
+	case errors.Is(err, errCorrupt):
+		corruptFilename := s.filename + ".corrupt"
+		if renameErr := os.Rename(s.filename, corruptFilename); renameErr != nil {
+			return fmt.Errorf("move corrupt file aside: %w", renameErr)
+		}
+		s.log.Warnf("moved corrupt state file to %q, loading the backup: %v", corruptFilename, err)
+	case errors.Is(err, fs.ErrNotExist):
+		// either a fresh start, or we stopped between moving the file to the backup and replacing it
+	default:
+		return err
 	}
 
-	dec := json.NewDecoder(s.file)
-	err = dec.Decode(&s.layouts)
-	if err != nil {
-		return fmt.Errorf("decode json: %w", err)
+	// the file will be created, or replaced, on the first save
+	s.dirty = true
+
+	st, version, err = readFile(s.backupFilename())
+	switch {
+	case err == nil:
+		s.useState(st, version)
+	case errors.Is(err, errCorrupt):
+		s.log.Warnf("backup state file is corrupt too, starting over: %v", err)
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		return err
 	}
 
 	return nil
 }
 
-func (s *LayoutStore) save() error {
+// state is what's written to the file.
+type state struct {
+	Version  int                                                        `json:"version"`
+	Metadata Metadata                                                   `json:"metadata"`
+	Layouts  map[hyprboard.Scope]map[string]map[string]hyprboard.Layout `json:"layouts"`
+}
+
+type Metadata struct {
+	CreatedAt time.Time `json:"created_at"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+func (s *LayoutStore) useState(st state, version int) {
+	s.layouts = st.Layouts
+	s.metadata = st.Metadata
+
+	if version < migrations.Version {
+		s.log.Infof("migrated state file from version %d to %d", version, migrations.Version)
+		// the old version is kept as the backup when it's written
+		s.dirty = true
+	}
+}
+
+// readFile reads a state file, migrating it to the current version, and returns the version it had.
+func readFile(filename string) (state, int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return state{}, 0, fmt.Errorf("read file: %w", err)
+	}
+
+	// older versions created the file empty, and only wrote it on the first save
+	if len(data) == 0 {
+		return newState(), 0, nil
+	}
+
+	data, version, err := migrations.Migrate(data)
+	switch {
+	case errors.Is(err, migrations.ErrTooNew):
+		// it's not ours to touch, a newer hyprboard can still use it
+		return state{}, version, fmt.Errorf("%q: %w", filename, err)
+	case err != nil:
+		return state{}, version, fmt.Errorf("%w %q: %w", errCorrupt, filename, err)
+	}
+
+	st := newState()
+	if err := json.Unmarshal(data, &st); err != nil {
+		return state{}, version, fmt.Errorf("%w %q: decode json: %w", errCorrupt, filename, err)
+	}
+	if st.Layouts == nil {
+		st.Layouts = make(map[hyprboard.Scope]map[string]map[string]hyprboard.Layout)
+	}
+
+	return st, version, nil
+}
+
+func newState() state {
+	return state{
+		Version:  migrations.Version,
+		Metadata: Metadata{},
+		Layouts:  make(map[hyprboard.Scope]map[string]map[string]hyprboard.Layout),
+	}
+}
+
+// Metadata returns the metadata of the state as of the last save.
+func (s *LayoutStore) Metadata() Metadata {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.metadata
+}
+
+// changed marks the layouts dirty, and schedules saving them.
+func (s *LayoutStore) changed() {
+	s.dirty = true
+
+	if s.timer != nil || s.closed {
+		return
+	}
+	s.timer = time.AfterFunc(s.debounce, s.flush)
+}
+
+func (s *LayoutStore) flush() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	s.timer = nil
+
+	if err := s.save(); err != nil {
+		// it's tried again on the next change and on close
+		s.log.Warnf("save layouts: %v", err)
+	}
+}
+
+// save writes the layouts to a new file, and swaps it with the current one, which is kept as a backup.
+func (s *LayoutStore) save() error {
 	if !s.dirty {
 		return nil
 	}
 
-	_, err := s.file.Seek(0, 0)
-	if err != nil {
-		return fmt.Errorf("seek to start of file: %w", err)
+	metadata := s.metadata
+	metadata.SavedAt = time.Now()
+	if metadata.CreatedAt.IsZero() {
+		metadata.CreatedAt = metadata.SavedAt
 	}
 
-	err = s.file.Truncate(0)
+	data, err := json.Marshal(state{
+		Version:  migrations.Version,
+		Metadata: metadata,
+		Layouts:  s.layouts,
+	})
 	if err != nil {
-		return fmt.Errorf("truncate file: %w", err)
+		return fmt.Errorf("encode json: %w", err)
 	}
+	data = append(data, '\n')
 
-	enc := json.NewEncoder(s.file)
-	err = enc.Encode(s.layouts)
+	dir := filepath.Dir(s.filename)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(s.filename)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("encode json: %w", err)
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	// nothing to remove once it's renamed
+	defer os.Remove(file.Name())
+
+	if err := writeAndSync(file, data); err != nil {
+		return err
+	}
+
+	err = os.Rename(s.filename, s.backupFilename())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("back up previous file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), s.filename); err != nil {
+		return fmt.Errorf("replace file: %w", err)
 	}
 
+	// the renames are only durable once the directory is synced
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+
+	s.metadata = metadata
 	s.dirty = false
 
 	return nil
 }
 
-func (s *LayoutStore) saveLooper(ctx context.Context) error {
-	defer s.file.Close()
+func writeAndSync(file *os.File, data []byte) error {
+	defer file.Close()
 
-	for {
-		select {
-		case <-ctx.Done():
-			err := s.save()
-			if err != nil {
-				return fmt.Errorf("save: %w", err)
-			}
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
 
-			return ctx.Err()
-		case <-time.After(time.Minute):
-			err := s.save()
-			if err != nil {
-				return fmt.Errorf("save: %w", err)
-			}
-		}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open directory: %w", err)
 	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync directory: %w", err)
+	}
+
+	return nil
 }
 
-func (s *LayoutStore) GetActiveLayout(window string) (map[string]hyprboard.Layout, error) {
-	layouts, ok := s.layouts[window]
+func (s *LayoutStore) GetActiveLayout(scope hyprboard.Scope, key string) (map[string]hyprboard.Layout, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	layouts, ok := s.layouts[scope][key]
 	if !ok {
 		return nil, nil
 	}
-	return layouts, nil
+	return maps.Clone(layouts), nil
 }
 
-func (s *LayoutStore) SetActiveLayout(window string, keyboard string, layout hyprboard.Layout) error {
-	layouts, ok := s.layouts[window]
+func (s *LayoutStore) SetActiveLayout(scope hyprboard.Scope, key string, keyboard string, layout hyprboard.Layout) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	scoped, ok := s.layouts[scope]
+	if !ok {
+		scoped = make(map[string]map[string]hyprboard.Layout)
+		s.layouts[scope] = scoped
+	}
+
+	layouts, ok := scoped[key]
 	if !ok {
 		layouts = make(map[string]hyprboard.Layout)
-		s.layouts[window] = layouts
+		scoped[key] = layouts
 	}
 	layouts[keyboard] = layout
-	s.dirty = true
+	s.changed()
 	return nil
 }
+
+func (s *LayoutStore) ForgetActiveLayout(scope hyprboard.Scope, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.layouts[scope][key]; !ok {
+		return nil
+	}
+
+	delete(s.layouts[scope], key)
+	s.changed()
+	return nil
+}
+
+func (s *LayoutStore) ListActiveLayouts(scope hyprboard.Scope) (map[string]map[string]hyprboard.Layout, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := make(map[string]map[string]hyprboard.Layout, len(s.layouts[scope]))
+	for key, layouts := range s.layouts[scope] {
+		ret[key] = maps.Clone(layouts)
+	}
+	return ret, nil
+}
@@ -0,0 +1,243 @@
+package json_test
+
+import (
+	"bytes"
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/json"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/json/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	us = hyprboard.Layout{Code: "us"}
+	hu = hyprboard.Layout{Code: "hu"}
+)
+
+func open(t *testing.T, filename string, opts ...json.Option) *json.LayoutStore {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	store, err := json.NewLayoutStore(ctx, filename, zap.NewNop().Sugar(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// closing saves right away, unlike cancelling, so nothing is written after the test is done
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func expectLayout(t *testing.T, store *json.LayoutStore, key string, want hyprboard.Layout) {
+	t.Helper()
+
+	layouts, err := store.GetActiveLayout(hyprboard.ScopeClass, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := layouts["kbd"]; got != want {
+		t.Errorf("expected %v for %q, got %v", want, key, got)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	layoutstore.RunConformance(t, layoutstore.Implementation{
+		Open: func(t *testing.T, dir string) hyprboard.ActiveLayoutStore {
+			return open(t, filepath.Join(dir, "state.json"))
+		},
+		Persistent: true,
+	})
+}
+
+func TestSavesAfterDebounce(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	store := open(t, filename, json.WithDebounce(10*time.Millisecond))
+	if err := store.SetActiveLayout(hyprboard.ScopeClass, "kitty", "kbd", hu); err != nil {
+		t.Fatal(err)
+	}
+
+	// the store is never closed, like when the daemon is killed
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(filename); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("layouts weren't saved")
+		}
+	}
+
+	expectLayout(t, open(t, filename), "kitty", hu)
+}
+
+func TestKeepsBackup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	for _, layout := range []hyprboard.Layout{us, hu} {
+		store := open(t, filename)
+		if err := store.SetActiveLayout(hyprboard.ScopeClass, "kitty", "kbd", layout); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectLayout(t, open(t, filename+".bak"), "kitty", us)
+	expectLayout(t, open(t, filename), "kitty", hu)
+}
+
+func TestRecoversFromCorruptFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	for _, layout := range []hyprboard.Layout{us, hu} {
+		store := open(t, filename)
+		if err := store.SetActiveLayout(hyprboard.ScopeClass, "kitty", "kbd", layout); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"class": {"kitty": {"kbd"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectLayout(t, open(t, filename), "kitty", us)
+
+	if _, err := os.Stat(filename + ".corrupt"); err != nil {
+		t.Errorf("expected the corrupt file to be kept: %v", err)
+	}
+}
+
+func TestRecoversFromInterruptedSave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	store := open(t, filename)
+	if err := store.SetActiveLayout(hyprboard.ScopeClass, "kitty", "kbd", hu); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a save that stopped after backing up the file, but before replacing it
+	if err := os.Rename(filename, filename+".bak"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectLayout(t, open(t, filename), "kitty", hu)
+}
+
+func TestMigratesOlderVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "class only", data: `{"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}`},
+		{name: "scoped", data: `{"class": {"kitty": {"kbd": {"Code": "hu", "Variant": ""}}}}`},
+		{name: "empty", data: ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "state.json")
+			if err := os.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store := open(t, filename)
+			if tt.data != "" {
+				expectLayout(t, store, "kitty", hu)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version, err := migrations.DetectVersion(data); err != nil || version != migrations.Version {
+				t.Errorf("expected the file to be migrated to version %d, got version %d (%v)", migrations.Version, version, err)
+			}
+
+			if tt.data != "" {
+				expectLayout(t, open(t, filename), "kitty", hu)
+			}
+		})
+	}
+}
+
+func TestMovesAsideInvalidVersions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	for _, layout := range []hyprboard.Layout{us, hu} {
+		store := open(t, filename)
+		if err := store.SetActiveLayout(hyprboard.ScopeClass, "kitty", "kbd", layout); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := []byte(`{"version": -1, "layouts": {}}`)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectLayout(t, open(t, filename), "kitty", us)
+
+	if got, err := os.ReadFile(filename + ".corrupt"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("expected the file to be kept as it was, got %q (%v)", got, err)
+	}
+}
+
+func TestRefusesNewerVersions(t *testing.T) {
+	data := []byte(fmt.Sprintf(`{"version": %d, "layouts": {}}`, migrations.Version+1))
+
+	tests := []struct {
+		name string
+		// file is where the newer file is, the other one doesn't exist
+		file string
+	}{
+		{name: "state file", file: "state.json"},
+		{name: "backup", file: "state.json.bak"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := json.NewLayoutStore(context.Background(), filepath.Join(dir, "state.json"), zap.NewNop().Sugar())
+			if !errors.Is(err, migrations.ErrTooNew) {
+				t.Fatalf("expected ErrTooNew, got %v", err)
+			}
+
+			// a newer version can still use it
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != tt.file {
+				t.Errorf("expected only %s in the directory, got %v", tt.file, entries)
+			}
+			if got, err := os.ReadFile(filepath.Join(dir, tt.file)); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("expected the file to be left alone, got %q (%v)", got, err)
+			}
+		})
+	}
+}
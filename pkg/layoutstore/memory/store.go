@@ -1,31 +1,67 @@
 package memory
 
-import "codeberg.org/miketth/hyprboard/pkg/hyprboard"
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"maps"
+	"sync"
+)
 
 type LayoutStore struct {
-	layouts map[string]map[string]hyprboard.Layout
+	layouts map[hyprboard.Scope]map[string]map[string]hyprboard.Layout
+	lock    sync.Mutex
 }
 
 func NewLayoutStore() *LayoutStore {
 	return &LayoutStore{
-		layouts: make(map[string]map[string]hyprboard.Layout),
+		layouts: make(map[hyprboard.Scope]map[string]map[string]hyprboard.Layout),
 	}
 }
 
-func (s *LayoutStore) GetActiveLayout(window string) (map[string]hyprboard.Layout, error) {
-	layouts, ok := s.layouts[window]
+func (s *LayoutStore) GetActiveLayout(scope hyprboard.Scope, key string) (map[string]hyprboard.Layout, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	layouts, ok := s.layouts[scope][key]
 	if !ok {
 		return nil, nil
 	}
-	return layouts, nil
+	return maps.Clone(layouts), nil
 }
 
-func (s *LayoutStore) SetActiveLayout(window string, keyboard string, layout hyprboard.Layout) error {
-	layouts, ok := s.layouts[window]
+func (s *LayoutStore) SetActiveLayout(scope hyprboard.Scope, key string, keyboard string, layout hyprboard.Layout) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	scoped, ok := s.layouts[scope]
+	if !ok {
+		scoped = make(map[string]map[string]hyprboard.Layout)
+		s.layouts[scope] = scoped
+	}
+
+	layouts, ok := scoped[key]
 	if !ok {
 		layouts = make(map[string]hyprboard.Layout)
-		s.layouts[window] = layouts
+		scoped[key] = layouts
 	}
 	layouts[keyboard] = layout
 	return nil
 }
+
+func (s *LayoutStore) ForgetActiveLayout(scope hyprboard.Scope, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.layouts[scope], key)
+	return nil
+}
+
+func (s *LayoutStore) ListActiveLayouts(scope hyprboard.Scope) (map[string]map[string]hyprboard.Layout, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := make(map[string]map[string]hyprboard.Layout, len(s.layouts[scope]))
+	for key, layouts := range s.layouts[scope] {
+		ret[key] = maps.Clone(layouts)
+	}
+	return ret, nil
+}
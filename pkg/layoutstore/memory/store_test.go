@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/memory"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	layoutstore.RunConformance(t, layoutstore.Implementation{
+		Open: func(t *testing.T, dir string) hyprboard.ActiveLayoutStore {
+			return memory.NewLayoutStore()
+		},
+	})
+}
@@ -24,15 +24,27 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.deleteLayoutsForAppStmt, err = db.PrepareContext(ctx, deleteLayoutsForApp); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteLayoutsForApp: %w", err)
+	}
 	if q.dumpRestStmt, err = db.PrepareContext(ctx, dumpRest); err != nil {
 		return nil, fmt.Errorf("error preparing query DumpRest: %w", err)
 	}
 	if q.dumpTablesStmt, err = db.PrepareContext(ctx, dumpTables); err != nil {
 		return nil, fmt.Errorf("error preparing query DumpTables: %w", err)
 	}
+	if q.getLayoutUsageStmt, err = db.PrepareContext(ctx, getLayoutUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLayoutUsage: %w", err)
+	}
 	if q.getLayoutsForAppStmt, err = db.PrepareContext(ctx, getLayoutsForApp); err != nil {
 		return nil, fmt.Errorf("error preparing query GetLayoutsForApp: %w", err)
 	}
+	if q.getLayoutsForScopeStmt, err = db.PrepareContext(ctx, getLayoutsForScope); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLayoutsForScope: %w", err)
+	}
+	if q.insertLayoutSwitchStmt, err = db.PrepareContext(ctx, insertLayoutSwitch); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertLayoutSwitch: %w", err)
+	}
 	if q.setLayoutStmt, err = db.PrepareContext(ctx, setLayout); err != nil {
 		return nil, fmt.Errorf("error preparing query SetLayout: %w", err)
 	}
@@ -41,6 +53,11 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 
 func (q *Queries) Close() error {
 	var err error
+	if q.deleteLayoutsForAppStmt != nil {
+		if cerr := q.deleteLayoutsForAppStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteLayoutsForAppStmt: %w", cerr)
+		}
+	}
 	if q.dumpRestStmt != nil {
 		if cerr := q.dumpRestStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing dumpRestStmt: %w", cerr)
@@ -51,11 +68,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing dumpTablesStmt: %w", cerr)
 		}
 	}
+	if q.getLayoutUsageStmt != nil {
+		if cerr := q.getLayoutUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLayoutUsageStmt: %w", cerr)
+		}
+	}
 	if q.getLayoutsForAppStmt != nil {
 		if cerr := q.getLayoutsForAppStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getLayoutsForAppStmt: %w", cerr)
 		}
 	}
+	if q.getLayoutsForScopeStmt != nil {
+		if cerr := q.getLayoutsForScopeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLayoutsForScopeStmt: %w", cerr)
+		}
+	}
+	if q.insertLayoutSwitchStmt != nil {
+		if cerr := q.insertLayoutSwitchStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertLayoutSwitchStmt: %w", cerr)
+		}
+	}
 	if q.setLayoutStmt != nil {
 		if cerr := q.setLayoutStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing setLayoutStmt: %w", cerr)
@@ -98,21 +130,29 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                   DBTX
-	tx                   *sql.Tx
-	dumpRestStmt         *sql.Stmt
-	dumpTablesStmt       *sql.Stmt
-	getLayoutsForAppStmt *sql.Stmt
-	setLayoutStmt        *sql.Stmt
+	db                      DBTX
+	tx                      *sql.Tx
+	deleteLayoutsForAppStmt *sql.Stmt
+	dumpRestStmt            *sql.Stmt
+	dumpTablesStmt          *sql.Stmt
+	getLayoutUsageStmt      *sql.Stmt
+	getLayoutsForAppStmt    *sql.Stmt
+	getLayoutsForScopeStmt  *sql.Stmt
+	insertLayoutSwitchStmt  *sql.Stmt
+	setLayoutStmt           *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                   tx,
-		tx:                   tx,
-		dumpRestStmt:         q.dumpRestStmt,
-		dumpTablesStmt:       q.dumpTablesStmt,
-		getLayoutsForAppStmt: q.getLayoutsForAppStmt,
-		setLayoutStmt:        q.setLayoutStmt,
+		db:                      tx,
+		tx:                      tx,
+		deleteLayoutsForAppStmt: q.deleteLayoutsForAppStmt,
+		dumpRestStmt:            q.dumpRestStmt,
+		dumpTablesStmt:          q.dumpTablesStmt,
+		getLayoutUsageStmt:      q.getLayoutUsageStmt,
+		getLayoutsForAppStmt:    q.getLayoutsForAppStmt,
+		getLayoutsForScopeStmt:  q.getLayoutsForScopeStmt,
+		insertLayoutSwitchStmt:  q.insertLayoutSwitchStmt,
+		setLayoutStmt:           q.setLayoutStmt,
 	}
 }
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"context"
+	"fmt"
+	"time"
+)
+
+type LayoutUsage struct {
+	App          string
+	Device       string
+	Layout       hyprboard.Layout
+	Duration     time.Duration
+	UserSwitches int
+}
+
+func (s *LayoutStore) RecordSwitch(app string, keyboard string, layout hyprboard.Layout, source hyprboard.SwitchSource) error {
+	if err := s.querier.InsertLayoutSwitch(context.Background(), InsertLayoutSwitchParams{
+		Timestamp: time.Now().UnixMilli(),
+		App:       app,
+		Device:    keyboard,
+		Code:      layout.Code,
+		Variant:   layout.Variant,
+		Source:    string(source),
+	}); err != nil {
+		return fmt.Errorf("sqlite insert: %w", err)
+	}
+
+	return nil
+}
+
+// LayoutUsage returns how long each layout was used in each app since the given time,
+// ordered by app and device, and the most used layout first.
+func (s *LayoutStore) LayoutUsage(since time.Time) ([]LayoutUsage, error) {
+	rows, err := s.querier.GetLayoutUsage(context.Background(), GetLayoutUsageParams{
+		Now:   time.Now().UnixMilli(),
+		Since: since.UnixMilli(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqlite select: %w", err)
+	}
+
+	usage := make([]LayoutUsage, 0, len(rows))
+	for _, row := range rows {
+		usage = append(usage, LayoutUsage{
+			App:    row.App,
+			Device: row.Device,
+			Layout: hyprboard.Layout{
+				Code:    row.Code,
+				Variant: row.Variant,
+			},
+			Duration:     time.Duration(row.Duration) * time.Millisecond,
+			UserSwitches: int(row.UserSwitches),
+		})
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"context"
+	"go.uber.org/zap"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLayoutUsageEndsAtStop(t *testing.T) {
+	store, err := NewLayoutStore(filepath.Join(t.TempDir(), "state.db"), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	switches := []struct {
+		at     time.Duration
+		app    string
+		device string
+		code   string
+		source hyprboard.SwitchSource
+	}{
+		{at: 0, app: "kitty", device: "kbd", code: "us", source: hyprboard.SwitchSourceFocus},
+		{at: time.Hour, app: "firefox", device: "kbd", code: "hu", source: hyprboard.SwitchSourceFocus},
+		{at: 2 * time.Hour, source: hyprboard.SwitchSourceStopped},
+		// the next morning
+		{at: 24 * time.Hour, app: "kitty", device: "kbd", code: "us", source: hyprboard.SwitchSourceFocus},
+	}
+	for _, s := range switches {
+		if err := store.querier.InsertLayoutSwitch(context.Background(), InsertLayoutSwitchParams{
+			Timestamp: start.Add(s.at).UnixMilli(),
+			App:       s.app,
+			Device:    s.device,
+			Code:      s.code,
+			Source:    string(s.source),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := store.querier.GetLayoutUsage(context.Background(), GetLayoutUsageParams{
+		Now:   start.Add(25 * time.Hour).UnixMilli(),
+		Since: start.UnixMilli(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]time.Duration{
+		"firefox": time.Hour,
+		"kitty":   2 * time.Hour,
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for _, row := range rows {
+		if got := time.Duration(row.Duration) * time.Millisecond; got != want[row.App] {
+			t.Errorf("expected %s for %q, got %s", want[row.App], row.App, got)
+		}
+	}
+}
@@ -9,14 +9,70 @@ import (
 	"context"
 )
 
+const deleteLayoutsForApp = `-- name: DeleteLayoutsForApp :exec
+delete from last_layouts
+where scope = ? and app = ?
+`
+
+type DeleteLayoutsForAppParams struct {
+	Scope string
+	App   string
+}
+
+func (q *Queries) DeleteLayoutsForApp(ctx context.Context, arg DeleteLayoutsForAppParams) error {
+	_, err := q.exec(ctx, q.deleteLayoutsForAppStmt, deleteLayoutsForApp, arg.Scope, arg.App)
+	return err
+}
+
 const getLayoutsForApp = `-- name: GetLayoutsForApp :many
-select app, device, code, variant
+select scope, app, device, code, variant
+from last_layouts
+where scope = ? and app = ?
+`
+
+type GetLayoutsForAppParams struct {
+	Scope string
+	App   string
+}
+
+func (q *Queries) GetLayoutsForApp(ctx context.Context, arg GetLayoutsForAppParams) ([]LastLayout, error) {
+	rows, err := q.query(ctx, q.getLayoutsForAppStmt, getLayoutsForApp, arg.Scope, arg.App)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LastLayout
+	for rows.Next() {
+		var i LastLayout
+		if err := rows.Scan(
+			&i.Scope,
+			&i.App,
+			&i.Device,
+			&i.Code,
+			&i.Variant,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLayoutsForScope = `-- name: GetLayoutsForScope :many
+select scope, app, device, code, variant
 from last_layouts
-where app = ?
+where scope = ?
+order by app, device
 `
 
-func (q *Queries) GetLayoutsForApp(ctx context.Context, app string) ([]LastLayout, error) {
-	rows, err := q.query(ctx, q.getLayoutsForAppStmt, getLayoutsForApp, app)
+func (q *Queries) GetLayoutsForScope(ctx context.Context, scope string) ([]LastLayout, error) {
+	rows, err := q.query(ctx, q.getLayoutsForScopeStmt, getLayoutsForScope, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +81,7 @@ func (q *Queries) GetLayoutsForApp(ctx context.Context, app string) ([]LastLayou
 	for rows.Next() {
 		var i LastLayout
 		if err := rows.Scan(
+			&i.Scope,
 			&i.App,
 			&i.Device,
 			&i.Code,
@@ -44,13 +101,14 @@ func (q *Queries) GetLayoutsForApp(ctx context.Context, app string) ([]LastLayou
 }
 
 const setLayout = `-- name: SetLayout :exec
-insert into last_layouts (app, device, code, variant)
-values (?1, ?2, ?3, ?4)
+insert into last_layouts (scope, app, device, code, variant)
+values (?1, ?2, ?3, ?4, ?5)
 on conflict do update
-set code = ?3, variant = ?4
+set code = ?4, variant = ?5
 `
 
 type SetLayoutParams struct {
+	Scope   string
 	App     string
 	Device  string
 	Code    string
@@ -59,6 +117,7 @@ type SetLayoutParams struct {
 
 func (q *Queries) SetLayout(ctx context.Context, arg SetLayoutParams) error {
 	_, err := q.exec(ctx, q.setLayoutStmt, setLayout,
+		arg.Scope,
 		arg.App,
 		arg.Device,
 		arg.Code,
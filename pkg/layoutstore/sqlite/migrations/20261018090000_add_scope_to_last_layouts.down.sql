@@ -0,0 +1,16 @@
+create table last_layouts_unscoped (
+    app text not null,
+    device text not null,
+    code text not null,
+    variant text not null,
+    primary key (app, device)
+);
+
+insert into last_layouts_unscoped (app, device, code, variant)
+select app, device, code, variant
+from last_layouts
+where scope = 'class';
+
+drop table last_layouts;
+
+alter table last_layouts_unscoped rename to last_layouts;
@@ -0,0 +1,16 @@
+create table last_layouts_scoped (
+    scope text not null default 'class',
+    app text not null,
+    device text not null,
+    code text not null,
+    variant text not null,
+    primary key (scope, app, device)
+);
+
+insert into last_layouts_scoped (scope, app, device, code, variant)
+select 'class', app, device, code, variant
+from last_layouts;
+
+drop table last_layouts;
+
+alter table last_layouts_scoped rename to last_layouts;
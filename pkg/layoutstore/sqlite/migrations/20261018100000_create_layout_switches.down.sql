@@ -0,0 +1 @@
+drop table layout_switches;
\ No newline at end of file
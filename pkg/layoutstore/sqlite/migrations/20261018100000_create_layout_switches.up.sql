@@ -0,0 +1,11 @@
+create table layout_switches (
+    id integer primary key autoincrement,
+    timestamp integer not null,
+    app text not null,
+    device text not null,
+    code text not null,
+    variant text not null,
+    source text not null
+);
+
+create index layout_switches_timestamp on layout_switches (timestamp);
@@ -7,12 +7,23 @@ package sqlite
 import ()
 
 type LastLayout struct {
+	Scope   string
 	App     string
 	Device  string
 	Code    string
 	Variant string
 }
 
+type LayoutSwitch struct {
+	ID        int64
+	Timestamp int64
+	App       string
+	Device    string
+	Code      string
+	Variant   string
+	Source    string
+}
+
 type SchemaMigration struct {
 	Version interface{}
 	Dirty   *bool
@@ -25,3 +36,8 @@ type SqliteMaster struct {
 	Rootpage *int64
 	Sql      *string
 }
+
+type SqliteSequence struct {
+	Name interface{}
+	Seq  interface{}
+}
@@ -0,0 +1,28 @@
+-- name: InsertLayoutSwitch :exec
+insert into layout_switches (timestamp, app, device, code, variant, source)
+values (?, ?, ?, ?, ?, ?);
+
+-- name: GetLayoutUsage :many
+select app, device, code, variant,
+       cast(sum(duration) as integer) as duration,
+       cast(sum(source = 'user') as integer) as user_switches
+from (
+    select app, device, code, variant, source,
+           -- a layout is used until the next switch on the device, or until hyprboard stopped
+           min(
+               coalesce(
+                   lead(timestamp) over (partition by device order by timestamp, id),
+                   cast(sqlc.arg(now) as integer)
+               ),
+               coalesce(
+                   (select min(stop.timestamp) from layout_switches stop
+                    where stop.source = 'stopped' and stop.timestamp >= layout_switches.timestamp),
+                   cast(sqlc.arg(now) as integer)
+               )
+           ) - timestamp as duration
+    from layout_switches
+    where timestamp >= sqlc.arg(since)
+)
+where source != 'stopped'
+group by app, device, code, variant
+order by app, device, duration desc;
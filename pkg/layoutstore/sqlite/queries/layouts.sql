@@ -1,10 +1,20 @@
 -- name: GetLayoutsForApp :many
 select *
 from last_layouts
-where app = ?;
+where scope = ? and app = ?;
+
+-- name: GetLayoutsForScope :many
+select *
+from last_layouts
+where scope = ?
+order by app, device;
 
 -- name: SetLayout :exec
-insert into last_layouts (app, device, code, variant)
-values (?1, ?2, ?3, ?4)
+insert into last_layouts (scope, app, device, code, variant)
+values (?1, ?2, ?3, ?4, ?5)
 on conflict do update
-set code = ?3, variant = ?4;
+set code = ?4, variant = ?5;
+
+-- name: DeleteLayoutsForApp :exec
+delete from last_layouts
+where scope = ? and app = ?;
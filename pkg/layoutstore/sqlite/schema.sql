@@ -1,13 +1,28 @@
-CREATE TABLE last_layouts (
+CREATE TABLE "last_layouts" (
+    scope text not null default 'class',
     app text not null,
     device text not null,
     code text not null,
     variant text not null,
-    primary key (app, device)
+    primary key (scope, app, device)
+);
+
+CREATE TABLE layout_switches (
+    id integer primary key autoincrement,
+    timestamp integer not null,
+    app text not null,
+    device text not null,
+    code text not null,
+    variant text not null,
+    source text not null
 );
 
 CREATE TABLE schema_migrations (version uint64,dirty bool);
 
+CREATE TABLE sqlite_sequence(name,seq);
+
+CREATE INDEX layout_switches_timestamp on layout_switches (timestamp);
+
 CREATE UNIQUE INDEX version_unique ON schema_migrations (version);
 
 
@@ -37,13 +37,20 @@ func (s *LayoutStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *LayoutStore) GetActiveLayout(window string) (map[string]hyprboard.Layout, error) {
-	layouts, err := s.querier.GetLayoutsForApp(context.Background(), window)
+func (s *LayoutStore) GetActiveLayout(scope hyprboard.Scope, key string) (map[string]hyprboard.Layout, error) {
+	layouts, err := s.querier.GetLayoutsForApp(context.Background(), GetLayoutsForAppParams{
+		Scope: string(scope),
+		App:   key,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("sqlite select: %w", err)
 	}
 
-	ret := make(map[string]hyprboard.Layout)
+	if len(layouts) == 0 {
+		return nil, nil
+	}
+
+	ret := make(map[string]hyprboard.Layout, len(layouts))
 	for _, layout := range layouts {
 		ret[layout.Device] = hyprboard.Layout{
 			Code:    layout.Code,
@@ -54,9 +61,10 @@ func (s *LayoutStore) GetActiveLayout(window string) (map[string]hyprboard.Layou
 	return ret, nil
 }
 
-func (s *LayoutStore) SetActiveLayout(window string, keyboard string, layout hyprboard.Layout) error {
+func (s *LayoutStore) SetActiveLayout(scope hyprboard.Scope, key string, keyboard string, layout hyprboard.Layout) error {
 	if err := s.querier.SetLayout(context.Background(), SetLayoutParams{
-		App:     window,
+		Scope:   string(scope),
+		App:     key,
 		Device:  keyboard,
 		Code:    layout.Code,
 		Variant: layout.Variant,
@@ -66,3 +74,35 @@ func (s *LayoutStore) SetActiveLayout(window string, keyboard string, layout hyp
 
 	return nil
 }
+
+func (s *LayoutStore) ForgetActiveLayout(scope hyprboard.Scope, key string) error {
+	if err := s.querier.DeleteLayoutsForApp(context.Background(), DeleteLayoutsForAppParams{
+		Scope: string(scope),
+		App:   key,
+	}); err != nil {
+		return fmt.Errorf("sqlite delete: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LayoutStore) ListActiveLayouts(scope hyprboard.Scope) (map[string]map[string]hyprboard.Layout, error) {
+	layouts, err := s.querier.GetLayoutsForScope(context.Background(), string(scope))
+	if err != nil {
+		return nil, fmt.Errorf("sqlite select: %w", err)
+	}
+
+	ret := make(map[string]map[string]hyprboard.Layout)
+	for _, layout := range layouts {
+		if ret[layout.App] == nil {
+			ret[layout.App] = make(map[string]hyprboard.Layout)
+		}
+
+		ret[layout.App][layout.Device] = hyprboard.Layout{
+			Code:    layout.Code,
+			Variant: layout.Variant,
+		}
+	}
+
+	return ret, nil
+}
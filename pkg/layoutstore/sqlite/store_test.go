@@ -0,0 +1,23 @@
+package sqlite_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore"
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/sqlite"
+	"go.uber.org/zap"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	layoutstore.RunConformance(t, layoutstore.Implementation{
+		Open: func(t *testing.T, dir string) hyprboard.ActiveLayoutStore {
+			store, err := sqlite.NewLayoutStore(filepath.Join(dir, "state.db"), zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+		Persistent: true,
+	})
+}
@@ -0,0 +1,151 @@
+package rules
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+type Layout struct {
+	Code    string `json:"layout"`
+	Variant string `json:"variant"`
+}
+
+// Rule picks default layouts for windows whose class and title match.
+// Patterns have to match the whole string, like Hyprland's window rules,
+// and an empty pattern matches anything. The "*" keyboard applies to
+// every keyboard that doesn't have its own entry.
+type Rule struct {
+	Class   string            `json:"class"`
+	Title   string            `json:"title"`
+	Layouts map[string]Layout `json:"layouts"`
+
+	classRe *regexp.Regexp
+	titleRe *regexp.Regexp
+}
+
+type File struct {
+	Rules []Rule `json:"rules"`
+	// Exclude lists class patterns of apps whose layout should never be changed.
+	Exclude []string `json:"exclude"`
+	// IgnoreDevices lists name patterns of keyboards whose layout should never be remembered or restored.
+	IgnoreDevices []string `json:"ignore_devices"`
+	// DeviceAliases maps a name to the keyboards that should share remembered layouts under it.
+	DeviceAliases map[string][]string `json:"device_aliases"`
+}
+
+type Rules struct {
+	rules         []Rule
+	exclude       []string
+	ignoreDevices []string
+	deviceAliases map[string][]string
+}
+
+func Load(path string, registry *xkblayouts.XkbConfigRegistry) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(registry); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	aliased := make(map[string]string)
+	for alias, devices := range file.DeviceAliases {
+		for _, device := range devices {
+			if other, ok := aliased[device]; ok && other != alias {
+				return nil, fmt.Errorf("keyboard %q is aliased to both %q and %q", device, other, alias)
+			}
+			aliased[device] = alias
+		}
+	}
+
+	return &Rules{
+		rules:         file.Rules,
+		exclude:       file.Exclude,
+		ignoreDevices: file.IgnoreDevices,
+		deviceAliases: file.DeviceAliases,
+	}, nil
+}
+
+func (r *Rule) compile(registry *xkblayouts.XkbConfigRegistry) error {
+	var err error
+	r.classRe, err = compilePattern(r.Class)
+	if err != nil {
+		return fmt.Errorf("compile class pattern: %w", err)
+	}
+
+	r.titleRe, err = compilePattern(r.Title)
+	if err != nil {
+		return fmt.Errorf("compile title pattern: %w", err)
+	}
+
+	if len(r.Layouts) == 0 {
+		return fmt.Errorf("no layouts given")
+	}
+
+	for keyboard, layout := range r.Layouts {
+		if registry.GetLayoutPrettyName(layout.Code, layout.Variant) == "" {
+			return fmt.Errorf("unknown layout %q with variant %q for keyboard %q", layout.Code, layout.Variant, keyboard)
+		}
+	}
+
+	return nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+func (r *Rule) matches(class, title string) bool {
+	if r.classRe != nil && !r.classRe.MatchString(class) {
+		return false
+	}
+	if r.titleRe != nil && !r.titleRe.MatchString(title) {
+		return false
+	}
+	return true
+}
+
+func (r *Rules) GetDefaultLayout(class, title string) map[string]hyprboard.Layout {
+	for _, rule := range r.rules {
+		if !rule.matches(class, title) {
+			continue
+		}
+
+		layouts := make(map[string]hyprboard.Layout, len(rule.Layouts))
+		for keyboard, layout := range rule.Layouts {
+			layouts[keyboard] = hyprboard.Layout{Code: layout.Code, Variant: layout.Variant}
+		}
+		return layouts
+	}
+
+	return nil
+}
+
+func (r *Rules) Exclusions() []string {
+	return r.exclude
+}
+
+func (r *Rules) IgnoredDevices() []string {
+	return r.ignoreDevices
+}
+
+func (r *Rules) DeviceAliases() map[string][]string {
+	return r.deviceAliases
+}
@@ -0,0 +1,183 @@
+package rules_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/hyprboard"
+	"codeberg.org/miketth/hyprboard/pkg/rules"
+	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const evdevXML = `<?xml version="1.0" encoding="UTF-8"?>
+<xkbConfigRegistry version="1.1">
+  <layoutList>
+    <layout>
+      <configItem>
+        <name>us</name>
+        <description>English (US)</description>
+      </configItem>
+      <variantList>
+        <variant>
+          <configItem>
+            <name>intl</name>
+            <description>English (US, intl., with dead keys)</description>
+          </configItem>
+        </variant>
+      </variantList>
+    </layout>
+    <layout>
+      <configItem>
+        <name>hu</name>
+        <description>Hungarian</description>
+      </configItem>
+    </layout>
+  </layoutList>
+</xkbConfigRegistry>
+`
+
+var (
+	us     = hyprboard.Layout{Code: "us"}
+	usIntl = hyprboard.Layout{Code: "us", Variant: "intl"}
+	hu     = hyprboard.Layout{Code: "hu"}
+)
+
+// load loads a rules file with the given contents, against a registry with us, us(intl) and hu.
+func load(t *testing.T, data string) (*rules.Rules, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	xmlPath := filepath.Join(dir, "evdev.xml")
+	if err := os.WriteFile(xmlPath, []byte(evdevXML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := xkblayouts.ParseLayouts(xmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rulesPath := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rulesPath, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return rules.Load(rulesPath, registry)
+}
+
+func TestGetDefaultLayout(t *testing.T) {
+	r, err := load(t, `{"rules": [
+		{"class": "kitty", "layouts": {"kbd": {"layout": "hu"}}},
+		{"class": "firefox|chromium", "title": ".*Mail.*", "layouts": {"kbd": {"layout": "us", "variant": "intl"}}},
+		{"class": "firefox", "layouts": {"*": {"layout": "us"}, "other": {"layout": "hu"}}},
+		{"title": "Terminal", "layouts": {"kbd": {"layout": "us"}}},
+		{"class": "kitty", "layouts": {"kbd": {"layout": "us"}}}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		class string
+		title string
+		want  map[string]hyprboard.Layout
+	}{
+		{name: "class", class: "kitty", title: "~", want: map[string]hyprboard.Layout{"kbd": hu}},
+		{name: "class and title", class: "firefox", title: "Inbox - Mail", want: map[string]hyprboard.Layout{"kbd": usIntl}},
+		{name: "alternatives", class: "chromium", title: "Mail", want: map[string]hyprboard.Layout{"kbd": usIntl}},
+		{
+			name:  "first rule wins",
+			class: "firefox",
+			title: "Mozilla Firefox",
+			want:  map[string]hyprboard.Layout{hyprboard.AllKeyboards: us, "other": hu},
+		},
+		{name: "empty class matches anything", class: "foot", title: "Terminal", want: map[string]hyprboard.Layout{"kbd": us}},
+		{name: "class is anchored", class: "kitty-dev", title: "~"},
+		{name: "title is anchored", class: "foot", title: "Terminal 2"},
+		{name: "no match", class: "steam", title: "Steam"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.GetDefaultLayout(tt.class, tt.title)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown layout",
+			data: `{"rules": [{"class": "kitty", "layouts": {"kbd": {"layout": "xx"}}}]}`,
+			err:  `unknown layout "xx"`,
+		},
+		{
+			name: "unknown variant",
+			data: `{"rules": [{"class": "kitty", "layouts": {"kbd": {"layout": "hu", "variant": "intl"}}}]}`,
+			err:  `unknown layout "hu" with variant "intl"`,
+		},
+		{
+			name: "no layouts",
+			data: `{"rules": [{"class": "kitty"}]}`,
+			err:  "no layouts given",
+		},
+		{
+			name: "invalid pattern",
+			data: `{"rules": [{"class": "kitty(", "layouts": {"kbd": {"layout": "hu"}}}]}`,
+			err:  "compile class pattern",
+		},
+		{
+			name: "conflicting aliases",
+			data: `{"device_aliases": {"laptop": ["kbd"], "desk": ["other", "kbd"]}}`,
+			err:  `keyboard "kbd" is aliased to both`,
+		},
+		{
+			name: "invalid json",
+			data: `{"rules": {}}`,
+			err:  "decode json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	r, err := load(t, `{
+		"exclude": ["steam"],
+		"ignore_devices": ["power-button"],
+		"device_aliases": {"laptop": ["kbd", "other"]}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.Exclusions(); len(got) != 1 || got[0] != "steam" {
+		t.Errorf("got exclusions %q", got)
+	}
+	if got := r.IgnoredDevices(); len(got) != 1 || got[0] != "power-button" {
+		t.Errorf("got ignored devices %q", got)
+	}
+	if got := r.DeviceAliases()["laptop"]; len(got) != 2 {
+		t.Errorf("got aliases %q", got)
+	}
+	if got := r.GetDefaultLayout("kitty", "~"); got != nil {
+		t.Errorf("expected no default layouts without rules, got %v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package xkblayouts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultLocaleDir = "/usr/share/locale"
+	gettextDomain    = "xkeyboard-config"
+	moMagic          = 0x950412de
+)
+
+// LocaleFromEnv returns the locale messages are shown in, following the usual environment variables.
+func LocaleFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// localeCandidates returns the names of catalog directories for a locale like "hu_HU.UTF-8@euro",
+// most specific first.
+func localeCandidates(locale string) []string {
+	locale, modifier, _ := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	language, _, hasTerritory := strings.Cut(locale, "_")
+
+	var candidates []string
+	if modifier != "" {
+		candidates = append(candidates, locale+"@"+modifier)
+	}
+	candidates = append(candidates, locale)
+	if hasTerritory {
+		candidates = append(candidates, language)
+	}
+
+	return candidates
+}
+
+// Catalog has the translations of layout descriptions, by the English description.
+type Catalog map[string]string
+
+// LoadCatalog reads the layout descriptions translated to the locale by xkeyboard-config, to pass to
+// WithTranslations. The catalog is looked for in localeDir, /usr/share/locale if empty.
+// It returns nil for the C locale or if there is no catalog for the locale.
+func LoadCatalog(locale, localeDir string) (Catalog, error) {
+	if locale == "" || locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		return nil, nil
+	}
+	if localeDir == "" {
+		localeDir = defaultLocaleDir
+	}
+
+	for _, candidate := range localeCandidates(locale) {
+		path := filepath.Join(localeDir, candidate, "LC_MESSAGES", gettextDomain+".mo")
+
+		catalog, err := readCatalog(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+
+		return catalog, nil
+	}
+
+	return nil, nil
+}
+
+// readCatalog reads the messages of a gettext .mo file. Contexts are dropped, and only the
+// singular form of plural messages is kept.
+func readCatalog(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	if len(data) < 20 {
+		return nil, fmt.Errorf("file too short")
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(data) == moMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data) == moMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a gettext catalog")
+	}
+
+	count := order.Uint32(data[8:])
+	originals := order.Uint32(data[12:])
+	translations := order.Uint32(data[16:])
+
+	str := func(table, i uint32) (string, error) {
+		pos := uint64(table) + uint64(i)*8
+		if pos+8 > uint64(len(data)) {
+			return "", fmt.Errorf("string %d out of bounds", i)
+		}
+
+		length := uint64(order.Uint32(data[pos:]))
+		offset := uint64(order.Uint32(data[pos+4:]))
+		if offset+length > uint64(len(data)) {
+			return "", fmt.Errorf("string %d out of bounds", i)
+		}
+
+		s, _, _ := strings.Cut(string(data[offset:offset+length]), "\x00")
+		return s, nil
+	}
+
+	catalog := make(Catalog, count)
+	for i := uint32(0); i < count; i++ {
+		msgid, err := str(originals, i)
+		if err != nil {
+			return nil, err
+		}
+		msgstr, err := str(translations, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, id, ok := strings.Cut(msgid, "\x04"); ok {
+			msgid = id
+		}
+		if msgid == "" {
+			// the header
+			continue
+		}
+
+		catalog[msgid] = msgstr
+	}
+
+	return catalog, nil
+}
@@ -0,0 +1,128 @@
+package xkblayouts_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// messages are sorted by msgid, like in real catalogs
+var messages = [][2]string{
+	{"", "Content-Type: text/plain; charset=UTF-8\n"},
+	{"English (US)", "Angol (USA)"},
+	{"Hungarian", "Magyar"},
+	{"layout\x04Hungarian (101/qwerty/comma/dead keys)", "Magyar (101/qwerty/vessző/halott billentyűk)"},
+}
+
+// buildCatalog makes a gettext .mo file with the messages.
+func buildCatalog(order binary.ByteOrder) []byte {
+	const headerSize = 28
+	n := uint32(len(messages))
+	originals := uint32(headerSize)
+	translations := originals + n*8
+	offset := translations + n*8
+
+	data := make([]byte, offset)
+	order.PutUint32(data[0:], 0x950412de)
+	order.PutUint32(data[8:], n)
+	order.PutUint32(data[12:], originals)
+	order.PutUint32(data[16:], translations)
+
+	for i, m := range messages {
+		for j, table := range []uint32{originals, translations} {
+			pos := table + uint32(i)*8
+			order.PutUint32(data[pos:], uint32(len(m[j])))
+			order.PutUint32(data[pos+4:], uint32(len(data)))
+			data = append(data, m[j]...)
+			data = append(data, 0)
+		}
+	}
+
+	return data
+}
+
+func writeCatalog(t *testing.T, locale string, data []byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, locale, "LC_MESSAGES", "xkeyboard-config.mo")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestLoadCatalog(t *testing.T) {
+	for name, order := range map[string]binary.ByteOrder{"little endian": binary.LittleEndian, "big endian": binary.BigEndian} {
+		t.Run(name, func(t *testing.T) {
+			dir := writeCatalog(t, "hu", buildCatalog(order))
+
+			catalog, err := xkblayouts.LoadCatalog("hu_HU.UTF-8", dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := xkblayouts.Catalog{
+				"English (US)": "Angol (USA)",
+				"Hungarian":    "Magyar",
+				// without the context
+				"Hungarian (101/qwerty/comma/dead keys)": "Magyar (101/qwerty/vessző/halott billentyűk)",
+			}
+			if len(catalog) != len(want) {
+				t.Errorf("expected %d messages, got %d: %q", len(want), len(catalog), catalog)
+			}
+			for msgid, msgstr := range want {
+				if catalog[msgid] != msgstr {
+					t.Errorf("expected %q for %q, got %q", msgstr, msgid, catalog[msgid])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCatalogTruncated(t *testing.T) {
+	data := buildCatalog(binary.LittleEndian)
+
+	for _, size := range []int{10, 40, len(data) - 5} {
+		dir := writeCatalog(t, "hu", data[:size])
+
+		if _, err := xkblayouts.LoadCatalog("hu", dir); err == nil {
+			t.Errorf("expected an error for a catalog truncated to %d bytes", size)
+		}
+	}
+}
+
+func TestLoadCatalogMissing(t *testing.T) {
+	catalog, err := xkblayouts.LoadCatalog("de_DE.UTF-8", writeCatalog(t, "hu", buildCatalog(binary.LittleEndian)))
+	if err != nil || catalog != nil {
+		t.Errorf("expected nothing for a locale without a catalog, got %q (%v)", catalog, err)
+	}
+}
+
+func TestWithTranslations(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "evdev.xml", `
+<layout><configItem><name>us</name><description>English (US)</description></configItem></layout>
+<layout><configItem><name>hu</name><description>Hungarian</description></configItem></layout>`)
+
+	registry, err := xkblayouts.LoadRegistry("evdev", []string{dir}, xkblayouts.WithTranslations(xkblayouts.Catalog{
+		"Hungarian": "Magyar",
+		// a translation can't take the place of a description
+		"English (US)": "Hungarian",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"Magyar": "hu", "Hungarian": "hu", "English (US)": "us"} {
+		if layout, _ := registry.GetLayoutAndVariantFromPrettyName(name); layout != want {
+			t.Errorf("expected %q to be %q, got %q", name, want, layout)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package xkblayouts
+
+import (
+	"sort"
+)
+
+type entryKey struct {
+	layout  string
+	variant string
+}
+
+type index struct {
+	entries map[entryKey]Entry
+	// byPrettyName has the keys with the same description in order of preference
+	byPrettyName map[string][]entryKey
+}
+
+// getIndex builds the lookup indexes on first use. The loaders build them right away, so registries
+// from them are safe for concurrent use.
+func (r *XkbConfigRegistry) getIndex() *index {
+	r.indexOnce.Do(func() {
+		r.index = r.buildIndex()
+	})
+	return r.index
+}
+
+func (r *XkbConfigRegistry) buildIndex() *index {
+	entries := r.Entries()
+	idx := &index{
+		entries:      make(map[entryKey]Entry, len(entries)),
+		byPrettyName: make(map[string][]entryKey, len(entries)),
+	}
+
+	for _, entry := range entries {
+		key := entryKey{layout: entry.Layout, variant: entry.Variant}
+		if _, ok := idx.entries[key]; ok {
+			continue
+		}
+
+		idx.entries[key] = entry
+		idx.byPrettyName[entry.Description] = append(idx.byPrettyName[entry.Description], key)
+	}
+
+	// untranslated descriptions are added first, so they still win if a translation collides with one
+	for _, entry := range entries {
+		translated, ok := r.translations[entry.Description]
+		if !ok || translated == "" || translated == entry.Description {
+			continue
+		}
+
+		idx.addPrettyName(translated, entryKey{layout: entry.Layout, variant: entry.Variant})
+	}
+
+	// the same description may be used more than once, prefer plain layouts
+	// over variants, then standard over exotic, then what came first
+	for _, keys := range idx.byPrettyName {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return idx.rank(keys[i]) < idx.rank(keys[j])
+		})
+	}
+
+	return idx
+}
+
+func (idx *index) rank(key entryKey) int {
+	rank := 0
+	if key.variant != "" {
+		rank += 2
+	}
+	if idx.entries[key].Exotic {
+		rank++
+	}
+	return rank
+}
+
+func (idx *index) addPrettyName(name string, key entryKey) {
+	for _, k := range idx.byPrettyName[name] {
+		if k == key {
+			return
+		}
+	}
+
+	idx.byPrettyName[name] = append(idx.byPrettyName[name], key)
+}
@@ -0,0 +1,135 @@
+package xkblayouts
+
+import (
+	"strings"
+)
+
+func newEntry(layout Layout, variant *Variant) Entry {
+	item := layout.ConfigItem
+	entry := Entry{
+		Layout:           item.Name,
+		Variant:          "",
+		ShortDescription: item.ShortDescription,
+		Description:      item.Description,
+		Countries:        item.CountryList.ISO3166Id,
+		Languages:        item.LanguageList.ISO639Id,
+		Exotic:           item.IsExotic(),
+		Source:           item.Source,
+	}
+
+	if variant == nil {
+		return entry
+	}
+
+	item = variant.ConfigItem
+	entry.Variant = item.Name
+	entry.Description = item.Description
+	entry.Exotic = entry.Exotic || item.IsExotic()
+	entry.Source = item.Source
+	if item.ShortDescription != "" {
+		entry.ShortDescription = item.ShortDescription
+	}
+	if len(item.CountryList.ISO3166Id) > 0 {
+		entry.Countries = item.CountryList.ISO3166Id
+	}
+	if len(item.LanguageList.ISO639Id) > 0 {
+		entry.Languages = item.LanguageList.ISO639Id
+	}
+
+	return entry
+}
+
+// Entries returns every layout and variant, each layout followed by its variants.
+func (r *XkbConfigRegistry) Entries() []Entry {
+	var entries []Entry
+	for _, l := range r.LayoutList.Layout {
+		entries = append(entries, newEntry(l, nil))
+		for i := range l.VariantList.Variant {
+			entries = append(entries, newEntry(l, &l.VariantList.Variant[i]))
+		}
+	}
+
+	return entries
+}
+
+func (r *XkbConfigRegistry) GetEntry(layout, variant string) (Entry, bool) {
+	entry, ok := r.getIndex().entries[entryKey{layout: layout, variant: variant}]
+	return entry, ok
+}
+
+// GetShortLabel returns the short description of a layout, like "hu", falling back to its code.
+func (r *XkbConfigRegistry) GetShortLabel(layout, variant string) string {
+	if entry, ok := r.GetEntry(layout, variant); ok && entry.ShortDescription != "" {
+		return entry.ShortDescription
+	}
+
+	return layout
+}
+
+// GetEntriesByLanguage returns the layouts and variants for an ISO 639 language code, like "hun".
+func (r *XkbConfigRegistry) GetEntriesByLanguage(language string) []Entry {
+	return r.filterEntries(func(e Entry) bool {
+		return containsFold(e.Languages, language)
+	})
+}
+
+// GetEntriesByCountry returns the layouts and variants for an ISO 3166 country code, like "HU".
+func (r *XkbConfigRegistry) GetEntriesByCountry(country string) []Entry {
+	return r.filterEntries(func(e Entry) bool {
+		return containsFold(e.Countries, country)
+	})
+}
+
+func (r *XkbConfigRegistry) filterEntries(keep func(Entry) bool) []Entry {
+	var entries []Entry
+	for _, entry := range r.Entries() {
+		if keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func containsFold(items []string, item string) bool {
+	for _, i := range items {
+		if strings.EqualFold(i, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOptionGroup returns an option group by name, like "grp".
+func (r *XkbConfigRegistry) GetOptionGroup(name string) (Group, bool) {
+	for _, g := range r.OptionList.Group {
+		if g.ConfigItem.Name == name {
+			return g, true
+		}
+	}
+
+	return Group{}, false
+}
+
+// GetOption returns an option, like "grp:alt_shift_toggle", and the group it's in.
+func (r *XkbConfigRegistry) GetOption(name string) (Option, Group, bool) {
+	for _, g := range r.OptionList.Group {
+		for _, o := range g.Option {
+			if o.ConfigItem.Name == name {
+				return o, g, true
+			}
+		}
+	}
+
+	return Option{}, Group{}, false
+}
+
+func (r *XkbConfigRegistry) GetModel(name string) (Model, bool) {
+	for _, m := range r.ModelList.Model {
+		if m.ConfigItem.Name == name {
+			return m, true
+		}
+	}
+
+	return Model{}, false
+}
@@ -6,7 +6,37 @@ import (
 	"os"
 )
 
-func ParseLayouts(path string) (*XkbConfigRegistry, error) {
+// LoadOption changes how a registry is loaded.
+type LoadOption func(r *XkbConfigRegistry)
+
+// WithTranslations makes the descriptions in the catalog known to GetLayoutAndVariantFromPrettyName.
+func WithTranslations(catalog Catalog) LoadOption {
+	return func(r *XkbConfigRegistry) {
+		r.translations = catalog
+	}
+}
+
+func ParseLayouts(path string, opts ...LoadOption) (*XkbConfigRegistry, error) {
+	registry, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	registry.build(opts)
+
+	return registry, nil
+}
+
+// build applies the options and builds the index, so the registry is safe for concurrent use.
+func (r *XkbConfigRegistry) build(opts []LoadOption) {
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.getIndex()
+}
+
+func parseFile(path string) (*XkbConfigRegistry, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
@@ -19,39 +49,35 @@ func ParseLayouts(path string) (*XkbConfigRegistry, error) {
 		return nil, fmt.Errorf("decode xml: %w", err)
 	}
 
+	registry.setSource(path)
+
 	return registry, nil
 }
 
 func (r *XkbConfigRegistry) GetLayoutPrettyName(layout, variant string) string {
-	for _, l := range r.LayoutList.Layout {
-		if l.ConfigItem.Name == layout {
-			if variant == "" {
-				return l.ConfigItem.Description
-			}
-
-			for _, v := range l.VariantList.Variant {
-				if v.ConfigItem.Name == variant {
-					return v.ConfigItem.Description
-				}
-			}
-		}
+	return r.getIndex().entries[entryKey{layout: layout, variant: variant}].Description
+}
+
+// GetLayoutAndVariantFromPrettyName returns the codes of the layout with the description, which can
+// also be translated if translations were loaded. If more layouts have it, the preferred one is returned.
+func (r *XkbConfigRegistry) GetLayoutAndVariantFromPrettyName(prettyName string) (string, string) {
+	keys := r.getIndex().byPrettyName[prettyName]
+	if len(keys) == 0 {
+		return "", ""
 	}
 
-	return ""
+	return keys[0].layout, keys[0].variant
 }
 
-func (r *XkbConfigRegistry) GetLayoutAndVariantFromPrettyName(prettyName string) (string, string) {
-	for _, l := range r.LayoutList.Layout {
-		if l.ConfigItem.Description == prettyName {
-			return l.ConfigItem.Name, ""
-		}
-
-		for _, v := range l.VariantList.Variant {
-			if v.ConfigItem.Description == prettyName {
-				return l.ConfigItem.Name, v.ConfigItem.Name
-			}
-		}
+// GetEntriesFromPrettyName returns every layout with the description, the preferred one first.
+func (r *XkbConfigRegistry) GetEntriesFromPrettyName(prettyName string) []Entry {
+	idx := r.getIndex()
+
+	keys := idx.byPrettyName[prettyName]
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, idx.entries[key])
 	}
 
-	return "", ""
+	return entries
 }
@@ -0,0 +1,162 @@
+package xkblayouts
+
+import (
+	"errors"
+	"fmt"
+	"github.com/adrg/xdg"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultConfigRoot = "/usr/share/X11/xkb"
+
+// SearchPaths returns the xkb directories in the order libxkbcommon looks in them, most important first.
+func SearchPaths() []string {
+	paths := []string{filepath.Join(xdg.ConfigHome, "xkb")}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".xkb"))
+	}
+
+	extraPath := os.Getenv("XKB_CONFIG_EXTRA_PATH")
+	if extraPath == "" {
+		extraPath = "/etc/xkb"
+	}
+	paths = append(paths, extraPath)
+
+	root := os.Getenv("XKB_CONFIG_ROOT")
+	if root == "" {
+		root = defaultConfigRoot
+	}
+	paths = append(paths, root)
+
+	return paths
+}
+
+// LoadRegistry reads rules/<ruleset>.xml and rules/<ruleset>.extras.xml from every directory and merges
+// them. Directories are in order of precedence, and the base file of a directory comes before the extras.
+// Files that don't exist are skipped, but at least one has to.
+func LoadRegistry(ruleset string, dirs []string, opts ...LoadOption) (*XkbConfigRegistry, error) {
+	registry := &XkbConfigRegistry{}
+	found := false
+
+	for _, dir := range dirs {
+		for _, name := range []string{ruleset + ".xml", ruleset + ".extras.xml"} {
+			path := filepath.Join(dir, "rules", name)
+
+			r, err := parseFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+
+			registry.merge(r)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no %s rules found in %v: %w", ruleset, dirs, fs.ErrNotExist)
+	}
+
+	registry.build(opts)
+
+	return registry, nil
+}
+
+// merge adds what's in other and not yet in r.
+func (r *XkbConfigRegistry) merge(other *XkbConfigRegistry) {
+	models := make(map[string]bool, len(r.ModelList.Model))
+	for _, m := range r.ModelList.Model {
+		models[m.ConfigItem.Name] = true
+	}
+	for _, m := range other.ModelList.Model {
+		if !models[m.ConfigItem.Name] {
+			r.ModelList.Model = append(r.ModelList.Model, m)
+		}
+	}
+
+	layouts := make(map[string]int, len(r.LayoutList.Layout))
+	for i, l := range r.LayoutList.Layout {
+		layouts[l.ConfigItem.Name] = i
+	}
+	for _, l := range other.LayoutList.Layout {
+		i, ok := layouts[l.ConfigItem.Name]
+		if !ok {
+			r.LayoutList.Layout = append(r.LayoutList.Layout, l)
+			continue
+		}
+
+		variants := r.LayoutList.Layout[i].VariantList.Variant
+		for _, v := range l.VariantList.Variant {
+			if !hasVariant(variants, v.ConfigItem.Name) {
+				variants = append(variants, v)
+			}
+		}
+		r.LayoutList.Layout[i].VariantList.Variant = variants
+	}
+
+	groups := make(map[string]int, len(r.OptionList.Group))
+	for i, g := range r.OptionList.Group {
+		groups[g.ConfigItem.Name] = i
+	}
+	for _, g := range other.OptionList.Group {
+		i, ok := groups[g.ConfigItem.Name]
+		if !ok {
+			r.OptionList.Group = append(r.OptionList.Group, g)
+			continue
+		}
+
+		options := r.OptionList.Group[i].Option
+		for _, o := range g.Option {
+			if !hasOption(options, o.ConfigItem.Name) {
+				options = append(options, o)
+			}
+		}
+		r.OptionList.Group[i].Option = options
+	}
+}
+
+func hasVariant(variants []Variant, name string) bool {
+	for _, v := range variants {
+		if v.ConfigItem.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOption(options []Option, name string) bool {
+	for _, o := range options {
+		if o.ConfigItem.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setSource marks every item as coming from the file at path.
+func (r *XkbConfigRegistry) setSource(path string) {
+	for i := range r.ModelList.Model {
+		r.ModelList.Model[i].ConfigItem.Source = path
+	}
+
+	for i := range r.LayoutList.Layout {
+		l := &r.LayoutList.Layout[i]
+		l.ConfigItem.Source = path
+		for j := range l.VariantList.Variant {
+			l.VariantList.Variant[j].ConfigItem.Source = path
+		}
+	}
+
+	for i := range r.OptionList.Group {
+		g := &r.OptionList.Group[i]
+		g.ConfigItem.Source = path
+		for j := range g.Option {
+			g.Option[j].ConfigItem.Source = path
+		}
+	}
+}
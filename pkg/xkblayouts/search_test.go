@@ -0,0 +1,109 @@
+package xkblayouts_test
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/xkblayouts"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRules writes an xkb rules file with the layouts, given as XML.
+func writeRules(t *testing.T, dir, name, layouts string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "rules", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<xkbConfigRegistry version="1.1"><layoutList>` + layouts + `</layoutList></xkbConfigRegistry>`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadRegistry(t *testing.T) {
+	userDir := t.TempDir()
+	systemDir := t.TempDir()
+	missingDir := filepath.Join(t.TempDir(), "missing")
+
+	userRules := writeRules(t, userDir, "evdev.xml", `
+<layout>
+  <configItem><name>us</name><description>English (mine)</description></configItem>
+  <variantList>
+    <variant><configItem><name>custom</name><description>English (custom)</description></configItem></variant>
+  </variantList>
+</layout>`)
+	systemRules := writeRules(t, systemDir, "evdev.xml", `
+<layout>
+  <configItem><name>us</name><description>English (US)</description></configItem>
+  <variantList>
+    <variant><configItem><name>intl</name><description>English (US, intl., with dead keys)</description></configItem></variant>
+  </variantList>
+</layout>
+<layout>
+  <configItem><name>hu</name><description>Hungarian</description></configItem>
+</layout>`)
+	systemExtras := writeRules(t, systemDir, "evdev.extras.xml", `
+<layout>
+  <configItem><name>us</name><description>English (extras)</description></configItem>
+  <variantList>
+    <variant><configItem><name>intl</name><description>English (intl, extras)</description></configItem></variant>
+    <variant><configItem><name>altgr-weur</name><description>English (Western European AltGr dead keys)</description></configItem></variant>
+  </variantList>
+</layout>
+<layout>
+  <configItem><name>hu</name><description>Hungarian (extras)</description></configItem>
+</layout>`)
+
+	registry, err := xkblayouts.LoadRegistry("evdev", []string{userDir, missingDir, systemDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layout, variant string
+		description     string
+		source          string
+	}{
+		// the user's files win over the system's
+		{layout: "us", description: "English (mine)", source: userRules},
+		{layout: "us", variant: "custom", description: "English (custom)", source: userRules},
+		// base files win over extras, variants are merged into the layout from the files before
+		{layout: "us", variant: "intl", description: "English (US, intl., with dead keys)", source: systemRules},
+		{layout: "us", variant: "altgr-weur", description: "English (Western European AltGr dead keys)", source: systemExtras},
+		{layout: "hu", description: "Hungarian", source: systemRules},
+	}
+	for _, tt := range tests {
+		entry, ok := registry.GetEntry(tt.layout, tt.variant)
+		if !ok {
+			t.Errorf("%s(%s) not found", tt.layout, tt.variant)
+			continue
+		}
+		if entry.Description != tt.description {
+			t.Errorf("expected %s(%s) to be %q, got %q", tt.layout, tt.variant, tt.description, entry.Description)
+		}
+		if entry.Source != tt.source {
+			t.Errorf("expected %s(%s) to come from %s, got %s", tt.layout, tt.variant, tt.source, entry.Source)
+		}
+	}
+
+	if n := len(registry.LayoutList.Layout); n != 2 {
+		t.Errorf("expected 2 layouts, got %d", n)
+	}
+	if layout, _ := registry.GetLayoutAndVariantFromPrettyName("English (extras)"); layout != "" {
+		t.Errorf("expected the overridden description to be gone, found it for %q", layout)
+	}
+}
+
+func TestLoadRegistryWithoutRules(t *testing.T) {
+	_, err := xkblayouts.LoadRegistry("evdev", []string{t.TempDir(), filepath.Join(t.TempDir(), "missing")})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+}
@@ -1,15 +1,58 @@
 package xkblayouts
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"sync"
+)
 
 type XkbConfigRegistry struct {
 	XMLName    xml.Name   `xml:"xkbConfigRegistry"`
+	ModelList  ModelList  `xml:"modelList"`
 	LayoutList LayoutList `xml:"layoutList"`
+	OptionList OptionList `xml:"optionList"`
+
+	// translations are known to GetLayoutAndVariantFromPrettyName besides the descriptions
+	translations Catalog
+	index        *index
+	indexOnce    sync.Once
 }
 
+const (
+	PopularityStandard = "standard"
+	PopularityExotic   = "exotic"
+)
+
 type ConfigItem struct {
-	Name        string `xml:"name"`
-	Description string `xml:"description"`
+	Name             string       `xml:"name"`
+	ShortDescription string       `xml:"shortDescription"`
+	Description      string       `xml:"description"`
+	Vendor           string       `xml:"vendor"`
+	CountryList      CountryList  `xml:"countryList"`
+	LanguageList     LanguageList `xml:"languageList"`
+	// Popularity is empty for standard items.
+	Popularity string `xml:"popularity,attr"`
+	// Source is the file the item was read from.
+	Source string `xml:"-"`
+}
+
+func (c ConfigItem) IsExotic() bool {
+	return c.Popularity == PopularityExotic
+}
+
+type CountryList struct {
+	ISO3166Id []string `xml:"iso3166Id"`
+}
+
+type LanguageList struct {
+	ISO639Id []string `xml:"iso639Id"`
+}
+
+type Model struct {
+	ConfigItem ConfigItem `xml:"configItem"`
+}
+
+type ModelList struct {
+	Model []Model `xml:"model"`
 }
 
 type Variant struct {
@@ -28,3 +71,30 @@ type Layout struct {
 type LayoutList struct {
 	Layout []Layout `xml:"layout"`
 }
+
+type Option struct {
+	ConfigItem ConfigItem `xml:"configItem"`
+}
+
+// Group is a set of related options, like the ones for switching layouts ("grp").
+type Group struct {
+	ConfigItem             ConfigItem `xml:"configItem"`
+	AllowMultipleSelection bool       `xml:"allowMultipleSelection,attr"`
+	Option                 []Option   `xml:"option"`
+}
+
+type OptionList struct {
+	Group []Group `xml:"group"`
+}
+
+// Entry is a layout or one of its variants, with what a variant doesn't have filled in from its layout.
+type Entry struct {
+	Layout           string
+	Variant          string
+	ShortDescription string
+	Description      string
+	Countries        []string
+	Languages        []string
+	Exotic           bool
+	Source           string
+}
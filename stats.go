@@ -0,0 +1,84 @@
+package main
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/layoutstore/sqlite"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+)
+
+func runStats(args []string) error {
+	stateFileDefault, err := getStateFile()
+	if err != nil {
+		return fmt.Errorf("get state file: %w", err)
+	}
+
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	stateFile := flags.String("state-file", stateFileDefault, "path to the sqlite state file (*.db)")
+	since := flags.Duration("since", 30*24*time.Hour, "how far back to look")
+	app := flags.String("app", "", "only show this app")
+	_ = flags.Parse(args)
+
+	if path.Ext(*stateFile) != ".db" {
+		return fmt.Errorf("stats are only kept in sqlite state files, not %q", *stateFile)
+	}
+	if _, err := os.Stat(*stateFile); err != nil {
+		return fmt.Errorf("state file: %w", err)
+	}
+
+	store, err := sqlite.NewLayoutStore(*stateFile, zap.NewNop().Sugar())
+	if err != nil {
+		return fmt.Errorf("open layout store: %w", err)
+	}
+	defer store.Close()
+
+	usage, err := store.LayoutUsage(time.Now().Add(-*since))
+	if err != nil {
+		return fmt.Errorf("get layout usage: %w", err)
+	}
+
+	type appDevice struct{ app, device string }
+	totals := make(map[appDevice]time.Duration)
+	for _, u := range usage {
+		totals[appDevice{u.App, u.Device}] += u.Duration
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "APP\tKEYBOARD\tLAYOUT\tTIME\tSHARE\tSWITCHES\tMOST USED")
+
+	var last appDevice
+	for _, u := range usage {
+		if *app != "" && u.App != *app {
+			continue
+		}
+
+		key := appDevice{u.App, u.Device}
+		share := 0.0
+		if total := totals[key]; total > 0 {
+			share = 100 * float64(u.Duration) / float64(total)
+		}
+
+		// rows come with the most used layout first for every app and keyboard
+		mostUsed := ""
+		if key != last {
+			mostUsed = "*"
+			last = key
+		}
+
+		layout := u.Layout.Code
+		if u.Layout.Variant != "" {
+			layout += "(" + u.Layout.Variant + ")"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f%%\t%d\t%s\n",
+			u.App, u.Device, layout, u.Duration.Round(time.Second), share, u.UserSwitches, mostUsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"codeberg.org/miketth/hyprboard/pkg/control"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// waybarStatus is what Waybar's custom modules expect with return-type json,
+// extended with the raw details for other status bars.
+type waybarStatus struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+	Alt     string `json:"alt"`
+
+	Keyboard    string `json:"keyboard"`
+	Layout      string `json:"layout"`
+	Variant     string `json:"variant"`
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	WindowClass string `json:"window_class"`
+}
+
+func runStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	socket := flags.String("socket", getControlSocket(), "path to the control socket")
+	follow := flags.Bool("follow", false, "keep running and print a line on every change")
+	keyboard := flags.String("keyboard", "", "only show this keyboard, the main one if empty")
+	_ = flags.Parse(args)
+
+	client := control.NewClient(*socket)
+	enc := json.NewEncoder(os.Stdout)
+
+	if !*follow {
+		var status control.Status
+		if err := client.Do(control.CommandStatus, nil, &status); err != nil {
+			return err
+		}
+
+		name := *keyboard
+		if name == "" {
+			if status.MainKeyboard == "" {
+				return errors.New("no layouts seen yet")
+			}
+			name = status.MainKeyboard
+		}
+
+		layout, ok := status.Layouts[name]
+		if !ok {
+			return fmt.Errorf("no layout seen yet for keyboard %q", name)
+		}
+
+		return enc.Encode(newWaybarStatus(control.LayoutEvent{
+			Keyboard: name,
+			Layout:   layout,
+			Class:    status.Window.Class,
+		}))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := client.Subscribe(ctx, func(ev control.LayoutEvent) error {
+		// one line per change, for the keyboard that's shown
+		shown := ev.Main
+		if *keyboard != "" {
+			shown = ev.Keyboard == *keyboard
+		}
+		if !shown {
+			return nil
+		}
+		return enc.Encode(newWaybarStatus(ev))
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+func newWaybarStatus(ev control.LayoutEvent) waybarStatus {
+	return waybarStatus{
+		Text:        ev.Layout.Label,
+		Tooltip:     fmt.Sprintf("%s on %s", ev.Layout.Name, ev.Keyboard),
+		Class:       ev.Layout.Code,
+		Alt:         ev.Layout.Code,
+		Keyboard:    ev.Keyboard,
+		Layout:      ev.Layout.Code,
+		Variant:     ev.Layout.Variant,
+		Name:        ev.Layout.Name,
+		Label:       ev.Layout.Label,
+		WindowClass: ev.Class,
+	}
+}